@@ -0,0 +1,50 @@
+package manners
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// agentStatus maps each phase to the status reported to HAProxy.
+var agentStatus = [...]string{
+	phaseStarting: "down",
+	phaseServing:  "ready",
+	phaseDraining: "drain",
+	phaseStopped:  "down",
+}
+
+// ServeAgentCheck listens on the TCP address addr and responds to each
+// connection with the server's state in HAProxy agent-check format: "ready"
+// while serving, "drain" once shutdown has started and "down" otherwise. With
+// the backend's agent-check enabled, HAProxy then stops sending new requests
+// as soon as the server starts draining. For example:
+//
+//	server app1 10.0.0.1:8080 check agent-check agent-port 8081 agent-inter 1s
+//
+// The listener is closed when the server has finished. ServeAgentCheck
+// returns once it is listening.
+func (s *GracefulServer) ServeAgentCheck(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Agent check for %s listening on %s\n", s.ident(), l.Addr())
+
+	go func() {
+		<-s.done
+		l.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(conn, "%s\n", agentStatus[atomic.LoadInt32(&s.phase)])
+			conn.Close()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,52 @@
+package manners
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAgentCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	server := NewWithOptions(Options{
+		Server:         new(http.Server),
+		AgentCheckAddr: addr,
+		DrainDelay:     100 * time.Millisecond,
+	})
+	server.Handler = nullHandler
+	_, errc := server.ServeInMemory()
+
+	status := func() string {
+		for {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				// The agent check starts listening in the Serve goroutine.
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			reply, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			return string(reply)
+		}
+	}
+
+	if reply := status(); reply != "ready\n" {
+		t.Errorf("Expected ready, got %q", reply)
+	}
+	server.Close()
+	if reply := status(); reply != "drain\n" {
+		t.Errorf("Expected drain, got %q", reply)
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -0,0 +1,196 @@
+package manners
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A CertDirectory serves TLS certificates loaded from a directory, choosing between
+// them by SNI. Each certificate is held in a file with the ".crt" extension and its
+// private key in a file with the same base name and the ".key" extension. The host
+// names each certificate serves are taken from its DNS names (including wildcards)
+// and its common name.
+//
+// Use it by setting the GetCertificate field of the TLS config, eg.
+//
+//	certs, err := manners.NewCertDirectory("/etc/ssl/sites")
+//	...
+//	s.ListenAndServeTLSWithConfig(&tls.Config{GetCertificate: certs.GetCertificate})
+type CertDirectory struct {
+	dir      string
+	mutex    sync.RWMutex
+	names    map[string]*tls.Certificate
+	first    *tls.Certificate
+	stamp    string
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCertDirectory loads all the certificate/key pairs in dir.
+func NewCertDirectory(dir string) (*CertDirectory, error) {
+	d := &CertDirectory{dir: dir, stop: make(chan struct{})}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload loads all the certificate/key pairs in the directory again. If any of them
+// cannot be loaded, an error is returned and the previous certificates remain in use.
+func (d *CertDirectory) Reload() error {
+	certFiles, err := filepath.Glob(filepath.Join(d.dir, "*.crt"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(certFiles)
+
+	stamp, err := d.fingerprint()
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]*tls.Certificate)
+	var first *tls.Certificate
+	for _, certFile := range certFiles {
+		keyFile := strings.TrimSuffix(certFile, ".crt") + ".key"
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("%s: %v", certFile, err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", certFile, err)
+		}
+
+		c := &cert
+		if first == nil {
+			first = c
+		}
+		if leaf.Subject.CommonName != "" {
+			names[strings.ToLower(leaf.Subject.CommonName)] = c
+		}
+		for _, name := range leaf.DNSNames {
+			names[strings.ToLower(name)] = c
+		}
+	}
+
+	if first == nil {
+		return fmt.Errorf("no certificates found in %s", d.dir)
+	}
+
+	d.mutex.Lock()
+	d.names = names
+	d.first = first
+	d.stamp = stamp
+	d.mutex.Unlock()
+	logger.Printf("Loaded %d certificates from %s\n", len(certFiles), d.dir)
+	return nil
+}
+
+// fingerprint summarises the names, sizes and modification times of the files in
+// the directory, so that changes can be detected cheaply.
+func (d *CertDirectory) fingerprint() (string, error) {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&b, "%s:%d:%d;", f.Name(), f.Size(), f.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// GetCertificate selects the certificate matching the server name requested by the
+// client, falling back to a wildcard certificate for the parent domain. If the client
+// does not use SNI, the first certificate (by file name) is returned.
+func (d *CertDirectory) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name == "" {
+		return d.first, nil
+	}
+	if cert, ok := d.names[name]; ok {
+		return cert, nil
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := d.names["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+	return nil, fmt.Errorf("no certificate for %q", hello.ServerName)
+}
+
+// ReloadOnSignal creates a go-routine that reloads the certificates whenever one of
+// the given OS signals is received. If no signals are specified, SIGHUP is used.
+// The go-routine runs until Close is called.
+func (d *CertDirectory) ReloadOnSignal(signals ...os.Signal) *CertDirectory {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, signals...)
+	go func() {
+		defer signal.Stop(sigchan)
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-sigchan:
+				if err := d.Reload(); err != nil {
+					logger.Printf("Failed to reload certificates: %v\n", err)
+				}
+			}
+		}
+	}()
+	return d
+}
+
+// ReloadOnChange creates a go-routine that polls the directory every interval and
+// reloads the certificates when any file in it has changed. Errors are logged and
+// the previous certificates remain in use. The go-routine runs until Close is
+// called. An error is returned if interval is not positive.
+func (d *CertDirectory) ReloadOnChange(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid reload interval %v", interval)
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			}
+			stamp, err := d.fingerprint()
+			d.mutex.RLock()
+			changed := err == nil && stamp != d.stamp
+			d.mutex.RUnlock()
+			if changed {
+				if err := d.Reload(); err != nil {
+					logger.Printf("Failed to reload certificates: %v\n", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the go-routines started by ReloadOnSignal and ReloadOnChange. The
+// certificates already loaded remain in use.
+func (d *CertDirectory) Close() {
+	d.stopOnce.Do(func() { close(d.stop) })
+}
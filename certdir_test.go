@@ -0,0 +1,126 @@
+package manners
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeCertPair(t *testing.T, dir, name string, hosts ...string) {
+	certPEM, keyPEM, err := GenerateSelfSignedCert(hosts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = NewCertDirectory(dir); err == nil {
+		t.Error("Expected an error for an empty directory")
+	}
+
+	writeCertPair(t, dir, "a", "a.example.com")
+	writeCertPair(t, dir, "b", "b.example.com", "*.b.example.com")
+
+	certs, err := NewCertDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(name string) *tls.Certificate {
+		cert, _ := certs.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		return cert
+	}
+
+	a, b := get("a.example.com"), get("b.example.com")
+	if a == nil || b == nil || a == b {
+		t.Fatalf("Expected distinct certificates, got %p and %p", a, b)
+	}
+	if get("www.b.example.com") != b {
+		t.Error("Wildcard certificate was not selected")
+	}
+	if get("") != a {
+		t.Error("First certificate was not the default")
+	}
+	if get("c.example.com") != nil {
+		t.Error("Unexpected certificate for unknown name")
+	}
+
+	writeCertPair(t, dir, "c", "c.example.com")
+	if err = certs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if get("c.example.com") == nil {
+		t.Error("Reloaded certificate was not found")
+	}
+}
+
+// newCertDirectory returns a directory holding a certificate for a.example.com.
+func newCertDirectory(t *testing.T) (string, *CertDirectory) {
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCertPair(t, dir, "a", "a.example.com")
+	certs, err := NewCertDirectory(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, certs
+}
+
+// awaitCertificate waits for the directory to serve a certificate for name.
+func awaitCertificate(t *testing.T, certs *CertDirectory, name string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cert, _ := certs.GetCertificate(&tls.ClientHelloInfo{ServerName: name}); cert != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Certificate for %s was not loaded", name)
+}
+
+func TestCertDirectoryReloadOnChange(t *testing.T) {
+	dir, certs := newCertDirectory(t)
+	defer os.RemoveAll(dir)
+	defer certs.Close()
+
+	if err := certs.ReloadOnChange(0); err == nil {
+		t.Error("Expected an error for a zero interval")
+	}
+	if err := certs.ReloadOnChange(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	writeCertPair(t, dir, "b", "b.example.com")
+	awaitCertificate(t, certs, "b.example.com")
+}
+
+func TestCertDirectoryReloadOnSignal(t *testing.T) {
+	dir, certs := newCertDirectory(t)
+	defer os.RemoveAll(dir)
+	defer certs.Close()
+
+	certs.ReloadOnSignal(syscall.SIGUSR2)
+	writeCertPair(t, dir, "b", "b.example.com")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	awaitCertificate(t, certs, "b.example.com")
+}
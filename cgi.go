@@ -0,0 +1,37 @@
+package manners
+
+import (
+	"net/http"
+	"net/http/cgi"
+	"sync/atomic"
+	"time"
+)
+
+// ServeCGI serves the current request as a CGI child process, via net/http/cgi.
+// The execution is tracked in the WaitGroup, so that if Close is called, eg. by
+// CloseOnInterrupt when the web server sends a signal, ServeCGI still completes the
+// request and BlockingClose waits for it.
+func (s *GracefulServer) ServeCGI() error {
+	if !s.beginServing() {
+		return http.ErrServerClosed
+	}
+	atomic.StoreInt32(&s.phase, phaseServing)
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
+
+	s.hookMutex.Lock()
+	gracefulHandler := newGracefulHandler(s.Server.Handler, &s.stats)
+	s.handler = gracefulHandler
+	s.hookMutex.Unlock()
+	go func() {
+		s.shutdown <- true
+		close(s.shutdown)
+		s.stats.begin()
+		s.emit(ShutdownRequested, nil, nil)
+		s.runOnShutdown()
+		gracefulHandler.Close()
+	}()
+
+	err := cgi.Serve(s.trackRoutine(gracefulHandler))
+	s.finish()
+	return err
+}
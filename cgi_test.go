@@ -0,0 +1,48 @@
+package manners
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServeCGI(t *testing.T) {
+	t.Setenv("REQUEST_METHOD", "GET")
+	t.Setenv("SERVER_PROTOCOL", "HTTP/1.1")
+	t.Setenv("HTTP_HOST", "manners")
+	t.Setenv("REQUEST_URI", "/hello")
+
+	out, err := ioutil.TempFile("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	server := NewServer()
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if active := server.active; active != 1 {
+			t.Errorf("Expected the request to be tracked, got %d active", active)
+		}
+		io.WriteString(w, "hello from "+r.URL.Path)
+	})
+	if err := server.ServeCGI(); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = stdout
+
+	out.Seek(0, io.SeekStart)
+	body, _ := ioutil.ReadAll(out)
+	if !strings.Contains(string(body), "hello from /hello") {
+		t.Errorf("Unexpected CGI output %q", body)
+	}
+	if server.DrainResult() == nil {
+		t.Error("Expected the server to have finished")
+	}
+}
@@ -0,0 +1,46 @@
+package manners
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// braintreeServer is the method set of GracefulServer in the original
+// github.com/braintree/manners package.
+type braintreeServer interface {
+	Close() bool
+	BlockingClose() bool
+	ListenAndServe() error
+	ListenAndServeTLS(certFile, keyFile string) error
+	ListenAndServeTLSWithConfig(config *tls.Config) error
+	Serve(listener net.Listener) error
+	StartRoutine()
+	FinishRoutine()
+	GetFile() (*os.File, error)
+	HijackListener(s *http.Server, config *tls.Config) (*GracefulServer, error)
+}
+
+// Test that the API of the original braintree/manners package is still
+// provided with the same signatures, so that migrating projects only need
+// to change their import path. This is mostly checked by the compiler.
+func TestBraintreeCompatibility(t *testing.T) {
+	var _ braintreeServer = NewServer()
+	var _ func() *GracefulServer = NewServer
+	var _ func(*http.Server) *GracefulServer = NewWithServer
+	var _ func(Options) *GracefulServer = NewWithOptions
+	var _ = Options{Server: nil, StateHandler: nil, Listener: nil}
+	var _ StateHandler = func(net.Conn, http.ConnState, http.ConnState) {}
+
+	var _ func(string, http.Handler) error = ListenAndServe
+	var _ func(string, string, string, http.Handler) error = ListenAndServeTLS
+	var _ func(net.Listener, http.Handler) error = Serve
+	var _ func() bool = Close
+
+	var _ func(net.Listener) *GracefulListener = NewListener
+	var _ func(net.Listener, *tls.Config) net.Listener = NewTLSListener
+	var _ net.Listener = TCPKeepAliveListener{}
+	var _ net.Listener = &GracefulListener{}
+}
@@ -0,0 +1,67 @@
+package manners
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+)
+
+// A ConnError describes a connection-level failure, such as a TLS handshake error,
+// a request aborted by the client or a failed write.
+type ConnError struct {
+	// RemoteAddr is the address of the client.
+	RemoteAddr string
+	// Op is "tls-handshake", "read" or "write".
+	Op  string
+	Err error
+}
+
+func (e *ConnError) Error() string {
+	return e.Op + " " + e.RemoteAddr + ": " + e.Err.Error()
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+// reportError passes a failed read or write to the error hook, if any. Errors
+// caused by closing the connection, the end of the stream and read timeouts,
+// which net/http uses to cancel reads, are not reported.
+func (g *gracefulConn) reportError(op string, err error) {
+	if g.onError == nil || err == nil || err == io.EOF || errors.Is(err, net.ErrClosed) {
+		return
+	}
+	var ne net.Error
+	if op == "read" && errors.As(err, &ne) && ne.Timeout() {
+		return
+	}
+	g.onError(&ConnError{RemoteAddr: g.Conn.RemoteAddr().String(), Op: op, Err: err})
+}
+
+const tlsHandshakeError = "http: TLS handshake error from "
+
+// errorLogWriter receives the messages of the http.Server's ErrorLog, reporting
+// TLS handshake errors to the error hook and passing all of them on to the
+// original ErrorLog, or the standard logger if there was none.
+type errorLogWriter struct {
+	onError func(*ConnError)
+	next    *log.Logger
+}
+
+func (w *errorLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	if strings.HasPrefix(msg, tlsHandshakeError) {
+		rest := strings.TrimPrefix(msg, tlsHandshakeError)
+		if i := strings.Index(rest, ": "); i >= 0 {
+			w.onError(&ConnError{RemoteAddr: rest[:i], Op: "tls-handshake", Err: errors.New(rest[i+2:])})
+		}
+	}
+	if w.next != nil {
+		w.next.Print(msg)
+	} else {
+		log.Print(msg)
+	}
+	return len(p), nil
+}
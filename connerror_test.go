@@ -0,0 +1,74 @@
+package manners
+
+import (
+	"errors"
+	helpers "github.com/rickb777/manners/test_helpers"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConnErrorTLSHandshake(t *testing.T) {
+	keyFile, err1 := helpers.NewTempFile(helpers.Key)
+	certFile, err2 := helpers.NewTempFile(helpers.Cert)
+	defer keyFile.Unlink()
+	defer certFile.Unlink()
+	if err1 != nil || err2 != nil {
+		t.Fatal("Failed to create temporary files", err1, err2)
+	}
+
+	connErrors := make(chan *ConnError, 1)
+	server := NewWithOptions(Options{
+		Server:      new(http.Server),
+		OnConnError: func(e *ConnError) { connErrors <- e },
+	})
+	listener, exitchan := startTLSServer(t, server, certFile.Name(), keyFile.Name(), nil)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	io.Copy(io.Discard, conn)
+	conn.Close()
+
+	select {
+	case e := <-connErrors:
+		if e.Op != "tls-handshake" || e.RemoteAddr != conn.LocalAddr().String() {
+			t.Errorf("Unexpected connection error %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TLS handshake error was not reported")
+	}
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestConnErrorReport(t *testing.T) {
+	var reported []*ConnError
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	g := newGracefulConn(c1)
+	g.onError = func(e *ConnError) { reported = append(reported, e) }
+
+	g.reportError("read", io.EOF)
+	g.reportError("read", net.ErrClosed)
+	g.reportError("read", &net.OpError{Op: "read", Err: timeoutError{}})
+	g.reportError("write", errors.New("broken pipe"))
+
+	if len(reported) != 1 || reported[0].Op != "write" {
+		t.Errorf("Unexpected reported errors %v", reported)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,146 @@
+package manners
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ConnInfo describes a connection tracked by a GracefulServer.
+type ConnInfo struct {
+	RemoteAddr net.Addr
+	State      http.ConnState
+	Opened     time.Time
+	LastRead   time.Time
+	LastWrite  time.Time
+}
+
+// LastActivity returns the time of the latest read or write.
+func (c ConnInfo) LastActivity() time.Time {
+	if c.LastWrite.After(c.LastRead) {
+		return c.LastWrite
+	}
+	return c.LastRead
+}
+
+// Connections returns a snapshot of the open connections, oldest first. It
+// returns nil unless Options.TrackConnections, StreamingGrace or DrainSilenceLimit
+// is set.
+func (s *GracefulServer) Connections() []ConnInfo {
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+	if s.conns == nil {
+		return nil
+	}
+	infos := make([]ConnInfo, 0, len(s.conns))
+	for g := range s.conns {
+		infos = append(infos, ConnInfo{
+			RemoteAddr: g.Conn.RemoteAddr(),
+			State:      http.ConnState(atomic.LoadInt32(&g.state)),
+			Opened:     time.Unix(0, g.opened),
+			LastRead:   time.Unix(0, atomic.LoadInt64(&g.lastRead)),
+			LastWrite:  time.Unix(0, atomic.LoadInt64(&g.lastWrite)),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Opened.Before(infos[j].Opened) })
+	return infos
+}
+
+// Read records the time of the read if the connection is tracked, and
+// reports any error to the error hook.
+func (g *gracefulConn) Read(b []byte) (int, error) {
+	n, err := g.Conn.Read(b)
+	if g.tracked && n > 0 {
+		atomic.StoreInt64(&g.lastRead, time.Now().UnixNano())
+	}
+	if err != nil {
+		g.reportError("read", err)
+	}
+	return n, err
+}
+
+// Write records the time of the write if the connection is tracked, and
+// reports any error to the error hook.
+func (g *gracefulConn) Write(b []byte) (int, error) {
+	if g.tracked {
+		atomic.StoreInt64(&g.lastWrite, time.Now().UnixNano())
+	}
+	n, err := g.Conn.Write(b)
+	if err != nil {
+		g.reportError("write", err)
+	}
+	return n, err
+}
+
+// trackConn registers a new connection so that its activity can be inspected.
+func (s *GracefulServer) trackConn(g *gracefulConn) {
+	now := time.Now().UnixNano()
+	g.tracked = true
+	g.opened = now
+	atomic.StoreInt64(&g.lastRead, now)
+	s.connsMutex.Lock()
+	if s.conns == nil {
+		s.conns = make(map[*gracefulConn]struct{})
+	}
+	s.conns[g] = struct{}{}
+	s.connsMutex.Unlock()
+}
+
+func (s *GracefulServer) untrackConn(g *gracefulConn) {
+	s.connsMutex.Lock()
+	delete(s.conns, g)
+	s.connsMutex.Unlock()
+}
+
+// closeConns closes the connections that have been inactive, in the sense given
+// by lastActive, for at least d. It returns the number left open.
+func (s *GracefulServer) closeConns(d time.Duration, lastActive func(*gracefulConn) int64) int {
+	now := time.Now().UnixNano()
+	open := 0
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+	for g := range s.conns {
+		if now-lastActive(g) < int64(d) {
+			open++
+		} else {
+			g.Conn.Close()
+		}
+	}
+	return open
+}
+
+func lastWrite(g *gracefulConn) int64 {
+	return atomic.LoadInt64(&g.lastWrite)
+}
+
+func lastReadOrWrite(g *gracefulConn) int64 {
+	r, w := atomic.LoadInt64(&g.lastRead), atomic.LoadInt64(&g.lastWrite)
+	if w > r {
+		return w
+	}
+	return r
+}
+
+// streamingActivity is how recently a connection must have been written to for
+// it to count as actively streaming.
+const streamingActivity = time.Second
+
+// awaitStreams closes the connections that are not streaming and gives the
+// others up to the streaming grace period to finish. It reports whether they did.
+func (s *GracefulServer) awaitStreams() bool {
+	n := s.closeConns(streamingActivity, lastWrite)
+	if n == 0 {
+		return false
+	}
+	logger.Printf("Allowing %d streaming connections on %s a further %v\n", n, s.ident(), s.streamingGrace)
+	timer := time.NewTimer(s.streamingGrace)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package manners
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that when the drain timeout expires, a streaming response is allowed to
+// finish while a stalled request is cut off.
+func TestStreamingGrace(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:         new(http.Server),
+		DrainTimeout:   50 * time.Millisecond,
+		StreamingGrace: time.Second,
+	})
+	started := make(chan bool, 2)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		for i := 0; i < 20; i++ {
+			w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	mux.HandleFunc("/stall", func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-r.Context().Done()
+	})
+	server.Handler = mux
+	client, errc := server.ServeInMemory()
+
+	type result struct {
+		body string
+		err  error
+	}
+	get := func(path string) chan result {
+		c := make(chan result, 1)
+		go func() {
+			resp, err := client.Get("http://manners" + path)
+			if err != nil {
+				c <- result{err: err}
+				return
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			c <- result{string(body), err}
+		}()
+		return c
+	}
+	stream := get("/stream")
+	stall := get("/stall")
+	<-started
+	<-started
+
+	server.Close()
+
+	if r := <-stall; r.err == nil {
+		t.Error("Expected the stalled request to be cut off")
+	}
+	if r := <-stream; r.err != nil || strings.Count(r.body, "chunk") != 20 {
+		t.Errorf("Expected the stream to complete, got %d chunks, %v", strings.Count(r.body, "chunk"), r.err)
+	}
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Tests that open connections are listed with their activity and that, during
+// the drain, silent connections are closed before the drain timeout.
+func TestConnections(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:            new(http.Server),
+		DrainSilenceLimit: 40 * time.Millisecond,
+	})
+	started := make(chan bool, 1)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-r.Context().Done()
+	})
+	client, errc := server.ServeInMemory()
+
+	before := time.Now()
+	stall := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("http://manners/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		stall <- err
+	}()
+	<-started
+
+	conns := server.Connections()
+	if len(conns) != 1 {
+		t.Fatalf("Expected one connection, got %+v", conns)
+	}
+	if c := conns[0]; c.State != http.StateActive || c.Opened.Before(before) || c.LastActivity().Before(c.Opened) {
+		t.Errorf("Unexpected connection %+v", c)
+	}
+
+	server.Close()
+	select {
+	case err := <-stall:
+		if err == nil {
+			t.Error("Expected the silent request to be cut off")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Silent connection was not closed")
+	}
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	if conns := server.Connections(); len(conns) != 0 {
+		t.Errorf("Expected no connections after shutdown, got %+v", conns)
+	}
+}
@@ -0,0 +1,133 @@
+package manners
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ServeControl opens a unix socket at path and accepts simple line-based
+// commands on it, so that operators and scripts can manage the server using
+// nc or a similar tool instead of signals. The commands are:
+//
+//	status      reports the server phase and the number of active routines
+//	simulate    reports what would hold up a drain, as per SimulateDrain
+//	snapshot    reports the server state as JSON, as per Snapshot
+//	drain       starts a graceful shutdown, as per Close
+//	force-stop  closes all connections immediately, as per Stop
+//
+// The socket is only accessible to the user running the server (mode 0600),
+// because anyone who can connect to it can stop the server. It is created in a
+// private directory and then moved into place, so it is never accessible to
+// others. Put it in a directory with suitable permissions if other users need
+// access.
+//
+// The socket is closed when the server has finished. ServeControl returns
+// once the socket is listening.
+func (s *GracefulServer) ServeControl(path string) error {
+	l, err := listenPrivateUnix(path)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Control socket for %s listening on %s\n", s.ident(), path)
+
+	go func() {
+		<-s.done
+		l.Close()
+		os.Remove(path)
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleControl(conn)
+		}
+	}()
+	return nil
+}
+
+// listenPrivateUnix listens on a unix socket at path with mode 0600. The socket
+// is created in a new directory with mode 0700 beside path, then renamed to
+// path, replacing any stale socket left there by an earlier run.
+func listenPrivateUnix(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".control")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(tmp, 0600); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (s *GracefulServer) handleControl(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		cmd := strings.TrimSpace(scanner.Text())
+		switch cmd {
+		case "":
+			continue
+
+		case "status":
+			fmt.Fprintf(conn, "%s active=%d\n",
+				phaseNames[atomic.LoadInt32(&s.phase)], atomic.LoadInt32(&s.active))
+
+		case "simulate":
+			sim := s.SimulateDrain()
+			fmt.Fprintf(conn, "routines=%d in-flight=%d\n", sim.Routines, sim.InFlight)
+			for _, c := range sim.Blocking {
+				fmt.Fprintf(conn, "%v %v opened=%s last-activity=%s\n", c.RemoteAddr, c.State,
+					c.Opened.Format(time.RFC3339), c.LastActivity().Format(time.RFC3339))
+			}
+			for _, h := range sim.Holds {
+				fmt.Fprintf(conn, "hold %s since=%s\n", h.Name, h.Since.Format(time.RFC3339))
+			}
+
+		case "snapshot":
+			if b, err := s.SnapshotJSON(); err != nil {
+				fmt.Fprintf(conn, "error: %v\n", err)
+			} else {
+				fmt.Fprintf(conn, "%s\n", b)
+			}
+
+		case "drain":
+			logger.Printf("Control socket: drain requested for %s\n", s.ident())
+			if !s.closeBeforeServe() {
+				go s.Close()
+			}
+			fmt.Fprintln(conn, "ok")
+
+		case "force-stop":
+			logger.Printf("Control socket: force-stop requested for %s\n", s.ident())
+			if err := s.Stop(); err != nil {
+				fmt.Fprintf(conn, "error: %v\n", err)
+			} else {
+				fmt.Fprintln(conn, "ok")
+			}
+
+		default:
+			fmt.Fprintf(conn, "error: unknown command %q\n", cmd)
+		}
+	}
+}
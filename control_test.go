@@ -0,0 +1,106 @@
+package manners
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.ctl")
+
+	server := NewServer()
+	_, exitchan := startServer(t, server, nil)
+	if err := server.ServeControl(path); err != nil {
+		t.Fatal("Failed to open control socket", err)
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected the control socket to be private, got %v %v", fi.Mode(), err)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal("Failed to connect to control socket", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	command := func(cmd string) string {
+		if _, err := conn.Write([]byte(cmd + "\n")); err != nil {
+			t.Fatal("Failed to write command", err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal("Failed to read reply", err)
+		}
+		return line
+	}
+
+	if reply := command("status"); reply != "serving active=0\n" {
+		t.Errorf("Unexpected status reply %q", reply)
+	}
+	if reply := command("simulate"); reply != "routines=0 in-flight=0\n" {
+		t.Errorf("Unexpected simulate reply %q", reply)
+	}
+	if reply := command("snapshot"); !strings.HasPrefix(reply, `{"server":`) {
+		t.Errorf("Unexpected snapshot reply %q", reply)
+	}
+	if reply := command("bogus"); reply != "error: unknown command \"bogus\"\n" {
+		t.Errorf("Unexpected reply %q", reply)
+	}
+	if reply := command("drain"); reply != "ok\n" {
+		t.Errorf("Unexpected drain reply %q", reply)
+	}
+
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Tests that the control socket replaces a stale one, leaves nothing else
+// behind and is removed when the server has finished.
+func TestControlSocketPlacement(t *testing.T) {
+	dir, err := os.MkdirTemp("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.ctl")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer()
+	_, exitchan := startServer(t, server, nil)
+	if err := server.ServeControl(path); err != nil {
+		t.Fatal("Failed to open control socket", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private socket in place of the stale file, got %v %v", fi.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the control socket in %s, got %d entries", dir, len(entries))
+	}
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("Expected the control socket to be removed")
+}
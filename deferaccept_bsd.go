@@ -0,0 +1,57 @@
+//go:build dragonfly || freebsd || netbsd
+// +build dragonfly freebsd netbsd
+
+package manners
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// acceptFilterArg is the "dataready" filter encoded as a struct accept_filter_arg,
+// which has a 16 byte name followed by a 240 byte argument.
+var acceptFilterArg = string(append([]byte("dataready"), make([]byte, 256-len("dataready"))...))
+
+func setDeferAccept(l *net.TCPListener) error {
+	raw, err := l.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_ACCEPTFILTER, acceptFilterArg)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// deferAcceptSet reports whether the "dataready" accept filter is installed on
+// the listener. The syscall package has no getsockopt for the filter's struct,
+// so it is read directly.
+func deferAcceptSet(l *net.TCPListener) (bool, error) {
+	raw, err := l.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	arg := make([]byte, len(acceptFilterArg))
+	size := uint32(len(arg))
+	var errno syscall.Errno
+	err = raw.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, syscall.SOL_SOCKET, syscall.SO_ACCEPTFILTER,
+			uintptr(unsafe.Pointer(&arg[0])), uintptr(unsafe.Pointer(&size)), 0)
+	})
+	if err != nil {
+		return false, err
+	}
+	if errno == syscall.EINVAL {
+		// no filter is installed
+		return false, nil
+	} else if errno != 0 {
+		return false, errno
+	}
+	return bytes.HasPrefix(arg, []byte("dataready\x00")), nil
+}
@@ -0,0 +1,42 @@
+package manners
+
+import (
+	"net"
+	"syscall"
+)
+
+// deferAcceptTimeout is how long, in seconds, the kernel waits for data on a new
+// connection before waking the accept loop anyway.
+const deferAcceptTimeout = 10
+
+func setDeferAccept(l *net.TCPListener) error {
+	raw, err := l.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT, deferAcceptTimeout)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// deferAcceptSet reports whether TCP_DEFER_ACCEPT is enabled on the listener.
+func deferAcceptSet(l *net.TCPListener) (bool, error) {
+	raw, err := l.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	var value int
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT)
+	})
+	if err != nil {
+		return false, err
+	}
+	return value > 0, sockErr
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !dragonfly && !freebsd && !netbsd
+// +build !linux,!dragonfly,!freebsd,!netbsd
+
+package manners
+
+import "net"
+
+func setDeferAccept(l *net.TCPListener) error {
+	return errDeferAcceptUnsupported
+}
+
+func deferAcceptSet(l *net.TCPListener) (bool, error) {
+	return false, errDeferAcceptUnsupported
+}
@@ -0,0 +1,43 @@
+package manners
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestDeferAccept(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), DeferAccept: true})
+	listener, exitchan := startServer(t, server, nil)
+
+	switch runtime.GOOS {
+	case "linux", "dragonfly", "freebsd", "netbsd":
+		tl, ok := listener.(*GracefulListener).listener.(*net.TCPListener)
+		if !ok {
+			t.Fatalf("Expected a TCP listener, got %T", listener.(*GracefulListener).listener)
+		}
+		if set, err := deferAcceptSet(tl); err != nil || !set {
+			t.Errorf("Expected deferred accept to be set on the listener, got %v %v", set, err)
+		}
+	default:
+		t.Log("deferred accept is not supported on " + runtime.GOOS)
+	}
+
+	client := newClient(listener.Addr(), false)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	if rr := <-client.response; rr.err != nil {
+		t.Fatal("Unexpected error from client", rr.err)
+	}
+	close(client.sendrequest)
+	<-client.closed
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -0,0 +1,126 @@
+package manners
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// A RoutineSite is a place in the code with routines that have been started but
+// not yet finished, as recorded when Options.RoutineDiagnostics is set. A site
+// whose routines stay outstanding is the usual reason that a server never
+// finishes shutting down.
+type RoutineSite struct {
+	// Site is the calling function, file and line, or the connection or
+	// hold that the routines belong to.
+	Site string
+	// Stack is the caller's stack for the oldest outstanding routine, if known.
+	Stack string
+	// Outstanding is the number of routines started here and not finished.
+	Outstanding int
+	// Since is when the oldest of them was started.
+	Since time.Time
+}
+
+// A routineEntry records one outstanding routine.
+type routineEntry struct {
+	site    string
+	stack   string
+	started time.Time
+}
+
+// callerSite describes the function, file and line of a caller; skip is as
+// per runtime.Caller, relative to the function calling callerSite.
+func callerSite(skip int) string {
+	pc, file, line, _ := runtime.Caller(skip + 1)
+	site := fmt.Sprintf("%s:%d", file, line)
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		site = fn.Name() + " " + site
+	}
+	return site
+}
+
+// callerStack returns the current goroutine's stack.
+func callerStack() string {
+	buf := make([]byte, 4096)
+	return string(buf[:runtime.Stack(buf, false)])
+}
+
+// startRoutine increments the WaitGroup and, with diagnostics, records an
+// entry for the routine, which must be passed to the matching finishRoutine.
+func (s *GracefulServer) startRoutine(site, stack string) *routineEntry {
+	s.wg.Add(1)
+	atomic.AddInt32(&s.active, 1)
+	if !s.diagnostics {
+		return nil
+	}
+	e := &routineEntry{site: site, stack: stack, started: time.Now()}
+	s.routinesMutex.Lock()
+	if s.routines == nil {
+		s.routines = make(map[*routineEntry]struct{})
+	}
+	s.routines[e] = struct{}{}
+	s.routinesMutex.Unlock()
+	return e
+}
+
+// finishRoutine decrements the WaitGroup and forgets the routine's entry.
+func (s *GracefulServer) finishRoutine(e *routineEntry) {
+	if e != nil {
+		s.routinesMutex.Lock()
+		delete(s.routines, e)
+		s.routinesMutex.Unlock()
+	}
+	atomic.AddInt32(&s.active, -1)
+	s.wg.Done()
+}
+
+// connSite identifies a connection's routine for diagnostics.
+func (s *GracefulServer) connSite(g *gracefulConn) string {
+	if !s.diagnostics {
+		return ""
+	}
+	return "connection from " + g.Conn.RemoteAddr().String()
+}
+
+// RoutineSites returns the sites with outstanding routines, oldest first. These
+// include StartRoutine callers, holds, and connections with requests in progress.
+// StartRoutine and FinishRoutine calls cannot be paired exactly, so each
+// FinishRoutine is taken to finish the most recent outstanding StartRoutine;
+// use Hold where exact attribution matters. It returns nil unless
+// Options.RoutineDiagnostics is set.
+func (s *GracefulServer) RoutineSites() []RoutineSite {
+	s.routinesMutex.Lock()
+	defer s.routinesMutex.Unlock()
+	bySite := make(map[string]*RoutineSite)
+	for e := range s.routines {
+		rs := bySite[e.site]
+		if rs == nil {
+			rs = &RoutineSite{Site: e.site}
+			bySite[e.site] = rs
+		}
+		rs.Outstanding++
+		if rs.Since.IsZero() || e.started.Before(rs.Since) {
+			rs.Since = e.started
+			rs.Stack = e.stack
+		}
+	}
+
+	var sites []RoutineSite
+	for _, rs := range bySite {
+		sites = append(sites, *rs)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Since.Before(sites[j].Since) })
+	return sites
+}
+
+// logRoutines reports the sites with outstanding routines, which are what the
+// drain is waiting for.
+func (s *GracefulServer) logRoutines() {
+	for _, rs := range s.RoutineSites() {
+		logger.Printf("Server on %s is waiting for %d routines started since %v at %s\n",
+			s.ident(), rs.Outstanding, rs.Since.Format(time.RFC3339), rs.Site)
+	}
+}
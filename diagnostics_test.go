@@ -0,0 +1,76 @@
+package manners
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRoutineDiagnostics(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), RoutineDiagnostics: true})
+
+	server.StartRoutine()
+	h := server.Hold("kafka-flush")
+	sites := server.RoutineSites()
+	if len(sites) != 2 {
+		t.Fatalf("Expected two outstanding sites, got %+v", sites)
+	}
+	for _, rs := range sites {
+		if rs.Outstanding != 1 || !strings.Contains(rs.Site, "diagnostics_test.go") ||
+			!strings.Contains(rs.Stack, "TestRoutineDiagnostics") {
+			t.Errorf("Expected the test's call sites, got %+v", rs)
+		}
+	}
+	if !strings.Contains(sites[1].Site, `hold "kafka-flush"`) {
+		t.Errorf("Expected the hold to be named, got %+v", sites[1])
+	}
+
+	h.Release()
+	server.FinishRoutine()
+	if sites := server.RoutineSites(); len(sites) != 0 {
+		t.Errorf("Expected no outstanding sites, got %+v", sites)
+	}
+
+	defer func() {
+		if r, ok := recover().(string); !ok || !strings.Contains(r, "diagnostics_test.go") {
+			t.Errorf("Expected FinishRoutine to panic naming its caller, got %v", r)
+		}
+	}()
+	server.FinishRoutine()
+}
+
+// Tests that a server whose routines are balanced, including those started
+// internally for each connection, reports nothing outstanding.
+func TestRoutineDiagnosticsBalanced(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), RoutineDiagnostics: true})
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.StartRoutine()
+		defer server.FinishRoutine()
+		server.Hold("request").Release()
+	})
+	client, errc := server.ServeInMemory()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://manners/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	if sites := server.RoutineSites(); len(sites) != 0 {
+		t.Errorf("Expected no outstanding sites, got %+v", sites)
+	}
+}
+
+func TestRoutineSitesDisabled(t *testing.T) {
+	server := NewServer()
+	server.StartRoutine()
+	if sites := server.RoutineSites(); sites != nil {
+		t.Errorf("Expected no sites, got %+v", sites)
+	}
+	server.FinishRoutine()
+}
@@ -0,0 +1,146 @@
+package manners
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A DrainResult describes how a GracefulServer shut down. It can be used to tune
+// the drain timeout with real data and to enforce limits on the time taken to drain.
+type DrainResult struct {
+	// ShutdownStarted is when Close was first called, for example on receipt of a signal.
+	ShutdownStarted time.Time
+	// Deregistered is when the server was removed from its load balancer, if
+	// Options.Deregister is set.
+	Deregistered time.Time
+	// ListenerClosed is when the listener stopped accepting connections.
+	ListenerClosed time.Time
+	// RequestsDone is when the last in-flight request or routine finished.
+	RequestsDone time.Time
+	// Finished is when the shutdown was complete.
+	Finished time.Time
+
+	// InFlight is the number of requests that were being handled when shutdown started.
+	InFlight int
+
+	// InFlightDurations holds, in ascending order, how long each of those requests
+	// took to finish after shutdown started. Requests that never finished, because
+	// their connections were closed by a drain budget, are not included.
+	InFlightDurations []time.Duration
+}
+
+// Duration returns the total time taken to shut down.
+func (r *DrainResult) Duration() time.Duration {
+	return r.Finished.Sub(r.ShutdownStarted)
+}
+
+// Percentile returns the duration within which the fraction p (between 0 and 1) of
+// the in-flight requests finished, using the nearest-rank method. It returns zero if
+// there were no in-flight requests.
+func (r *DrainResult) Percentile(p float64) time.Duration {
+	n := len(r.InFlightDurations)
+	if n == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(n))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= n {
+		i = n - 1
+	}
+	return r.InFlightDurations[i]
+}
+
+// drainStats tracks the requests in flight, so that those still running when
+// shutdown starts can be timed.
+type drainStats struct {
+	inFlight int32 // accessed atomically.
+	started  int64 // accessed atomically; UnixNano when shutdown started, or zero.
+
+	mutex  sync.Mutex
+	result DrainResult
+}
+
+func (d *drainStats) begin() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := time.Now()
+	d.result.ShutdownStarted = now
+	d.result.InFlight = int(atomic.LoadInt32(&d.inFlight))
+	atomic.StoreInt64(&d.started, now.UnixNano())
+}
+
+// mark records the current time in one of the timeline fields.
+func (d *drainStats) mark(field func(*DrainResult) *time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	*field(&d.result) = time.Now()
+}
+
+func (d *drainStats) requestStarted() time.Time {
+	atomic.AddInt32(&d.inFlight, 1)
+	return time.Now()
+}
+
+func (d *drainStats) requestFinished(start time.Time) {
+	atomic.AddInt32(&d.inFlight, -1)
+	started := atomic.LoadInt64(&d.started)
+	if started == 0 || start.UnixNano() >= started {
+		return
+	}
+	d.mutex.Lock()
+	d.result.InFlightDurations = append(d.result.InFlightDurations, time.Since(time.Unix(0, started)))
+	d.mutex.Unlock()
+}
+
+// finish returns the result, with the durations sorted.
+func (d *drainStats) finish() *DrainResult {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.result.Finished = time.Now()
+	result := d.result
+	result.InFlightDurations = append([]time.Duration(nil), d.result.InFlightDurations...)
+	sort.Slice(result.InFlightDurations, func(i, j int) bool {
+		return result.InFlightDurations[i] < result.InFlightDurations[j]
+	})
+	return &result
+}
+
+// defaultProgressInterval is used when Options.DrainProgressInterval is not set.
+const defaultProgressInterval = time.Second
+
+// reportProgress periodically logs what the drain is waiting for, and closes
+// connections that have been silent for the drain silence limit, until the
+// server has finished. Unless the interval is set, silent connections are
+// checked for at least four times per silence limit.
+func (s *GracefulServer) reportProgress() {
+	s.logHolds()
+	interval := s.progressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+		if s.drainSilenceLimit > 0 && s.drainSilenceLimit/4 < interval {
+			interval = s.drainSilenceLimit / 4
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if s.drainSilenceLimit > 0 {
+				s.closeConns(s.drainSilenceLimit, lastReadOrWrite)
+			}
+			logger.Printf("Draining %s: %d routines and %d requests remaining\n", s.ident(),
+				atomic.LoadInt32(&s.active), atomic.LoadInt32(&s.stats.inFlight))
+			s.logHolds()
+			if s.diagnostics {
+				s.logRoutines()
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package manners
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that a connection which never completes its request is closed once
+// the drain timeout expires.
+func TestDrainTimeout(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), DrainTimeout: 50 * time.Millisecond})
+	statechanged := make(chan http.ConnState, 100)
+	listener, exitchan := startServer(t, server, statechanged)
+
+	client := newClient(listener.Addr(), false)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	waitForState(t, statechanged, http.StateNew, "Request not received")
+
+	server.Close()
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down after the drain timeout")
+	}
+	close(client.sendrequest)
+}
+
+// Tests that once the permitted number of requests have completed after
+// shutdown starts, the remaining connections are closed.
+func TestDrainRequestLimit(t *testing.T) {
+	release := make(chan bool)
+	server := NewWithOptions(Options{Server: new(http.Server), DrainRequestLimit: 1})
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	statechanged := make(chan http.ConnState, 100)
+	listener, exitchan := startServer(t, server, statechanged)
+
+	client1 := newClient(listener.Addr(), false)
+	client1.Run()
+	if err := <-client1.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	waitForState(t, statechanged, http.StateNew, "Request not received")
+
+	client2 := newClient(listener.Addr(), false)
+	client2.Run()
+	if err := <-client2.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	waitForState(t, statechanged, http.StateNew, "Request not received")
+
+	client1.sendrequest <- true
+	waitForState(t, statechanged, http.StateActive, "Client failed to reach active state")
+	server.Close()
+	for !server.handler.IsClosed() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-client1.response
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down after the drain request limit")
+	}
+	close(client1.sendrequest)
+	close(client2.sendrequest)
+}
+
+// Tests that requests in flight at shutdown are timed in the drain result.
+func TestDrainResult(t *testing.T) {
+	release := make(chan bool)
+	server := NewServer()
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	statechanged := make(chan http.ConnState, 100)
+	listener, exitchan := startServer(t, server, statechanged)
+
+	client := newClient(listener.Addr(), false)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	waitForState(t, statechanged, http.StateActive, "Client failed to reach active state")
+	for atomic.LoadInt32(&server.stats.inFlight) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if server.DrainResult() != nil {
+		t.Error("Drain result should be nil while serving")
+	}
+	server.Close()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-client.response
+	close(client.sendrequest)
+
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	result := server.DrainResult()
+	if result == nil || result.InFlight != 1 || len(result.InFlightDurations) != 1 {
+		t.Fatalf("Unexpected drain result %+v", result)
+	}
+	if result.Percentile(1) < 20*time.Millisecond {
+		t.Errorf("Duration %v is too short", result.Percentile(1))
+	}
+	if !(result.ShutdownStarted.Before(result.ListenerClosed) &&
+		result.ListenerClosed.Before(result.RequestsDone) &&
+		!result.Finished.Before(result.RequestsDone)) {
+		t.Errorf("Timeline is out of order %+v", result)
+	}
+	if result.Duration() < 20*time.Millisecond {
+		t.Errorf("Shutdown duration %v is too short", result.Duration())
+	}
+}
+
+func TestDrainResultPercentile(t *testing.T) {
+	result := &DrainResult{InFlightDurations: []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+	for p, expected := range map[float64]time.Duration{0: 1, 0.5: 5, 0.9: 9, 0.95: 10, 1: 10} {
+		if d := result.Percentile(p); d != expected {
+			t.Errorf("Percentile(%v) = %v, expected %v", p, d, expected)
+		}
+	}
+	if d := (&DrainResult{}).Percentile(0.5); d != 0 {
+		t.Errorf("Expected zero for no requests, got %v", d)
+	}
+}
+
+// Tests that the server is deregistered before the drain delay and that this
+// is recorded in the drain timeline.
+func TestDeregister(t *testing.T) {
+	var calls int32
+	server := NewWithOptions(Options{
+		Server:     new(http.Server),
+		DrainDelay: 20 * time.Millisecond,
+		Deregister: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("target not found")
+		},
+	})
+	_, exitchan := startServer(t, server, nil)
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected Deregister to be called once, got %d", calls)
+	}
+	r := server.DrainResult()
+	if r.Deregistered.Before(r.ShutdownStarted) || r.ListenerClosed.Sub(r.Deregistered) < 20*time.Millisecond {
+		t.Errorf("Unexpected timeline %+v", r)
+	}
+}
+
+type recordingDrainable struct {
+	name     string
+	drained  *[]string
+	deadline time.Time
+}
+
+func (d *recordingDrainable) Drain(ctx context.Context) error {
+	*d.drained = append(*d.drained, d.name)
+	d.deadline, _ = ctx.Deadline()
+	return nil
+}
+
+// Tests that subsystems are drained in order, within the drain timeout.
+func TestDrainables(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), DrainTimeout: time.Second})
+	var drained []string
+	queue := &recordingDrainable{name: "queue", drained: &drained}
+	hub := &recordingDrainable{name: "hub", drained: &drained}
+	server.AddDrainable(queue)
+	server.AddDrainable(hub)
+
+	_, exitchan := startServer(t, server, nil)
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	if len(drained) != 2 || drained[0] != "queue" || drained[1] != "hub" {
+		t.Errorf("Unexpected drain order %v", drained)
+	}
+	r := server.DrainResult()
+	if hub.deadline.Before(r.ShutdownStarted.Add(time.Second)) || hub.deadline.After(r.ListenerClosed.Add(time.Second)) {
+		t.Errorf("Expected deadline one second after the listener closed at %v, got %v", r.ListenerClosed, hub.deadline)
+	}
+}
+
+// Tests that the drain delay does not use up the subsystems' drain timeout.
+func TestDrainablesAfterDrainDelay(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:       new(http.Server),
+		DrainDelay:   100 * time.Millisecond,
+		DrainTimeout: 50 * time.Millisecond,
+	})
+	var drained []string
+	queue := &recordingDrainable{name: "queue", drained: &drained}
+	server.AddDrainable(queue)
+
+	_, exitchan := startServer(t, server, nil)
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	r := server.DrainResult()
+	if len(drained) != 1 || !queue.deadline.After(r.ShutdownStarted.Add(150*time.Millisecond)) {
+		t.Errorf("Expected the deadline to start after the drain delay at %v, got %v", r.ShutdownStarted, queue.deadline)
+	}
+}
+
+// Tests that silent connections are only looked for at the configured
+// progress interval.
+func TestDrainProgressInterval(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:                new(http.Server),
+		DrainSilenceLimit:     10 * time.Millisecond,
+		DrainProgressInterval: 200 * time.Millisecond,
+	})
+	started := make(chan bool, 1)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-r.Context().Done()
+	})
+	client, errc := server.ServeInMemory()
+
+	stall := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("http://manners/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		stall <- err
+	}()
+	<-started
+
+	closed := time.Now()
+	server.Close()
+	select {
+	case <-stall:
+		if elapsed := time.Since(closed); elapsed < 200*time.Millisecond {
+			t.Errorf("Silent connection was closed after %v, before the progress interval", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Silent connection was not closed")
+	}
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -0,0 +1,52 @@
+package manners
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// A Drainable is a background subsystem, such as a queue consumer, scheduler or
+// websocket hub, that must finish its work when the server shuts down. Drain should
+// stop the subsystem taking on new work and return once its current work is done,
+// or when ctx is done.
+type Drainable interface {
+	Drain(ctx context.Context) error
+}
+
+// AddDrainable registers a subsystem to be drained when the server shuts down.
+// Subsystems are drained in the order they were added, after all HTTP requests
+// have completed. If a DrainTimeout is set, the context passed to them expires
+// at the end of whatever remains of it. Like the timeout for requests, this is
+// counted from when the listener closes, after any Deregister and DrainDelay.
+func (s *GracefulServer) AddDrainable(d Drainable) {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	s.drainables = append(s.drainables, d)
+}
+
+func (s *GracefulServer) drainSubsystems() {
+	s.hookMutex.Lock()
+	drainables := s.drainables
+	s.hookMutex.Unlock()
+	if s.hijackPolicy == HijackDrain {
+		drainables = append([]Drainable{hijackedConns{s}}, drainables...)
+	}
+	if len(drainables) == 0 {
+		return
+	}
+
+	s.trace.step("manners.subsystem_drain")
+	ctx := context.Background()
+	if deadline := atomic.LoadInt64(&s.drainDeadline); deadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, time.Unix(0, deadline))
+		defer cancel()
+	}
+
+	for _, d := range drainables {
+		if err := d.Drain(ctx); err != nil {
+			logger.Printf("Failed to drain %T on %s: %v\n", d, s.ident(), err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package manners
+
+// A DrainLock coordinates draining between processes, so that at most one of
+// the servers sharing it drains at a time. This prevents a rolling restart from
+// draining every instance on a host at once and causing a dip in capacity.
+// NewFileDrainLock provides an implementation using a lock file; a lease held
+// in a shared store can be used instead by implementing this interface.
+type DrainLock interface {
+	// Lock blocks until the lock is held.
+	Lock() error
+	// Unlock releases the lock.
+	Unlock() error
+}
+
+// lockDrain acquires the drain lock, if any, and reports whether it is held.
+// If it cannot be acquired, the server drains anyway rather than never
+// shutting down.
+func (s *GracefulServer) lockDrain() bool {
+	if s.drainLock == nil {
+		return false
+	}
+	s.trace.step("manners.drain_lock")
+	logger.Printf("Waiting for the drain lock for %s\n", s.ident())
+	if err := s.drainLock.Lock(); err != nil {
+		logger.Printf("Draining %s without the drain lock: %v\n", s.ident(), err)
+		return false
+	}
+	return true
+}
+
+func (s *GracefulServer) unlockDrain() {
+	if err := s.drainLock.Unlock(); err != nil {
+		logger.Printf("Failed to release the drain lock for %s: %v\n", s.ident(), err)
+	}
+}
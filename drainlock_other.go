@@ -0,0 +1,22 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package manners
+
+import "errors"
+
+type fileDrainLock struct{}
+
+// NewFileDrainLock returns a DrainLock using an exclusive lock on the file at path.
+// This is not supported on this platform, so the server drains without the lock.
+func NewFileDrainLock(path string) DrainLock {
+	return fileDrainLock{}
+}
+
+func (fileDrainLock) Lock() error {
+	return errors.New("file drain locks are not supported on this platform")
+}
+
+func (fileDrainLock) Unlock() error {
+	return nil
+}
@@ -0,0 +1,108 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package manners
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test that a server does not drain while another holds the drain lock, and
+// that it releases the lock once it has finished.
+func TestDrainLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drain.lock")
+
+	other := NewFileDrainLock(path)
+	if err := other.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewWithOptions(Options{Server: new(http.Server), DrainLock: NewFileDrainLock(path)})
+	_, exitchan := startServer(t, server, nil)
+	server.Close()
+
+	select {
+	case <-exitchan:
+		t.Fatal("Server drained while another held the drain lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := other.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not drain after the drain lock was released")
+	}
+
+	locked := make(chan error)
+	go func() {
+		locked <- other.Lock()
+	}()
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+		other.Unlock()
+	case <-time.After(time.Second):
+		t.Fatal("Server did not release the drain lock")
+	}
+}
+
+// Test that a server waiting for the drain lock continues to report that it is
+// ready until the server holding the lock has finished.
+func TestDrainLockReadiness(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drain.lock")
+
+	first := NewWithOptions(Options{Server: new(http.Server), DrainLock: NewFileDrainLock(path), DrainDelay: 100 * time.Millisecond})
+	second := NewWithOptions(Options{Server: new(http.Server), DrainLock: NewFileDrainLock(path)})
+	_, exitFirst := startServer(t, first, nil)
+	_, exitSecond := startServer(t, second, nil)
+
+	ready := func(s *GracefulServer) int {
+		rec := httptest.NewRecorder()
+		s.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		return rec.Code
+	}
+
+	first.Close()
+	for ready(first) == http.StatusOK {
+		time.Sleep(time.Millisecond)
+	}
+	second.Close()
+
+	if code := ready(second); code != http.StatusOK {
+		t.Errorf("Expected the waiting server to stay ready, got %d", code)
+	}
+	if second.isDraining() {
+		t.Error("Expected the waiting server not to be draining")
+	}
+
+	if err := <-exitFirst; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	if err := <-exitSecond; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
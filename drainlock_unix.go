@@ -0,0 +1,57 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package manners
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+type fileDrainLock struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileDrainLock returns a DrainLock using an exclusive flock(2) on the file at
+// path, which is created if necessary. Servers in different processes on the same
+// host share the lock by using the same path. The lock is released automatically
+// if the process exits.
+func NewFileDrainLock(path string) DrainLock {
+	return &fileDrainLock{path: path}
+}
+
+func (l *fileDrainLock) Lock() error {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return &os.PathError{Op: "flock", Path: l.path, Err: err}
+	}
+	l.mutex.Lock()
+	l.file = f
+	l.mutex.Unlock()
+	return nil
+}
+
+func (l *fileDrainLock) Unlock() error {
+	l.mutex.Lock()
+	f := l.file
+	l.file = nil
+	l.mutex.Unlock()
+	if f == nil {
+		return nil
+	}
+	// Closing the file releases the lock.
+	return f.Close()
+}
@@ -0,0 +1,33 @@
+package manners
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DrainEnvoy returns a function, for use as Options.Deregister, that asks the local
+// Envoy sidecar to drain its listeners gracefully, via the admin endpoint at adminURL,
+// eg. "http://127.0.0.1:9901". Envoy then tells downstream clients to go elsewhere
+// during the drain delay, before the server closes its own listener, so that
+// sidecar-based deployments drain in the right order. Other service meshes can be
+// notified by providing a different Deregister function.
+func DrainEnvoy(adminURL string) func(context.Context) error {
+	url := strings.TrimSuffix(adminURL, "/") + "/drain_listeners?graceful"
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := webhookClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("envoy drain_listeners: unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package manners
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDrainEnvoy(t *testing.T) {
+	var method, uri string
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, uri = r.Method, r.URL.RequestURI()
+	}))
+	defer admin.Close()
+
+	if err := DrainEnvoy(admin.URL + "/")(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if method != "POST" || uri != "/drain_listeners?graceful" {
+		t.Errorf("Unexpected admin request %s %s", method, uri)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if err := DrainEnvoy(missing.URL)(context.Background()); err == nil {
+		t.Error("Expected an error for a failed drain request")
+	}
+}
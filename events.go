@@ -0,0 +1,89 @@
+package manners
+
+import (
+	"net"
+	"time"
+)
+
+// EventKind identifies a point in the lifecycle of a GracefulServer.
+type EventKind int
+
+const (
+	// ListenerUp is emitted when the server starts accepting connections.
+	ListenerUp EventKind = iota
+	// ShutdownRequested is emitted when Close is first called.
+	ShutdownRequested
+	// ConnOpened is emitted when a connection is accepted.
+	ConnOpened
+	// ConnClosed is emitted when a connection is closed or hijacked.
+	ConnClosed
+	// DrainComplete is emitted when the server has finished; it is the last event.
+	DrainComplete
+)
+
+var eventKindNames = []string{"ListenerUp", "ShutdownRequested", "ConnOpened", "ConnClosed", "DrainComplete"}
+
+func (k EventKind) String() string {
+	return eventKindNames[k]
+}
+
+// An Event reports a change in the lifecycle of a GracefulServer.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Server identifies the server, as in its log lines; see Options.Name.
+	Server string
+	// Addr is the listener address for ListenerUp and the client address for
+	// ConnOpened and ConnClosed; otherwise it is nil.
+	Addr net.Addr
+	// Result describes the shutdown for DrainComplete; otherwise it is nil.
+	Result *DrainResult
+}
+
+// eventBuffer is the capacity of the events channel.
+const eventBuffer = 256
+
+// Events returns a channel on which the server's lifecycle events are sent, for
+// applications that want to drive dashboards or orchestration without polling.
+// The channel is closed after DrainComplete has been sent.
+//
+// Events are never allowed to delay the server: if the channel's buffer is full
+// because it is not being read quickly enough, events are dropped.
+// This function must be called before ListenAndServe, ListenAndServeTLS, or Serve.
+func (s *GracefulServer) Events() <-chan Event {
+	if s.events == nil {
+		s.events = make(chan Event, eventBuffer)
+	}
+	return s.events
+}
+
+// emit sends an event unless the channel has been closed. Connections that were
+// idle when the server finished can still close afterwards, so emit must not
+// assume that the server is running.
+func (s *GracefulServer) emit(kind EventKind, addr net.Addr, result *DrainResult) {
+	if s.events == nil {
+		return
+	}
+	s.eventsMutex.Lock()
+	defer s.eventsMutex.Unlock()
+	if s.eventsClosed {
+		return
+	}
+	select {
+	case s.events <- Event{Kind: kind, Time: time.Now(), Server: s.ident(), Addr: addr, Result: result}:
+	default:
+	}
+}
+
+// closeEvents closes the events channel; no more events are sent after this.
+func (s *GracefulServer) closeEvents() {
+	if s.events == nil {
+		return
+	}
+	s.eventsMutex.Lock()
+	defer s.eventsMutex.Unlock()
+	if !s.eventsClosed {
+		s.eventsClosed = true
+		close(s.events)
+	}
+}
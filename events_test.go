@@ -0,0 +1,144 @@
+package manners
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	server := NewServer()
+	events := server.Events()
+	statechanged := make(chan http.ConnState, 100)
+	listener, exitchan := startServer(t, server, statechanged)
+
+	client := newClient(listener.Addr(), false)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	<-client.response
+	close(client.sendrequest)
+	<-client.closed
+	waitForState(t, statechanged, http.StateClosed, "Client failed to reach closed state")
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	var kinds []EventKind
+	var last Event
+	for e := range events {
+		kinds = append(kinds, e.Kind)
+		last = e
+	}
+	expected := []EventKind{ListenerUp, ConnOpened, ConnClosed, ShutdownRequested, DrainComplete}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected events %v, got %v", expected, kinds)
+	}
+	if last.Result == nil {
+		t.Error("DrainComplete should carry the drain result")
+	}
+}
+
+func TestEventsServerName(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), Name: "api"})
+	server.Handler = nullHandler
+	events := server.Events()
+	_, errc := server.ServeInMemory()
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	for e := range events {
+		if e.Server != "api (pipe)" {
+			t.Errorf("Expected the server name and address in %v event, got %q", e.Kind, e.Server)
+		}
+	}
+}
+
+// Tests that connections left idle by keep-alive, which close after the server
+// has finished, do not send on the closed events channel.
+func TestEventsKeepAliveClose(t *testing.T) {
+	closed := make(chan bool, 1)
+	server := NewWithOptions(Options{
+		Server: &http.Server{Handler: nullHandler},
+		StateHandler: func(conn net.Conn, oldState, newState http.ConnState) {
+			if newState == http.StateClosed {
+				closed <- true
+			}
+		},
+	})
+	events := server.Events()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- server.Serve(&lazyCloseListener{l, release})
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	for range events {
+	}
+
+	// Now let the idle connection close, after the channel has been closed.
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Idle connection was not closed")
+	}
+	http.DefaultClient.CloseIdleConnections()
+}
+
+// lazyCloseListener accepts connections whose Close only takes effect once
+// release is closed, so that they outlive the server.
+type lazyCloseListener struct {
+	net.Listener
+	release chan struct{}
+}
+
+func (l *lazyCloseListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &lazyCloseConn{Conn: conn, release: l.release}, nil
+}
+
+type lazyCloseConn struct {
+	net.Conn
+	release chan struct{}
+	once    sync.Once
+}
+
+func (c *lazyCloseConn) Close() error {
+	c.once.Do(func() {
+		go func() {
+			<-c.release
+			c.Conn.Close()
+		}()
+	})
+	return nil
+}
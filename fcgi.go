@@ -0,0 +1,62 @@
+package manners
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"os"
+	"syscall"
+)
+
+// ServeFCGIStdin serves FastCGI on the listening socket inherited as standard input,
+// as a traditional FastCGI child process does under Apache mod_fcgid or spawn-fcgi.
+// The server drains gracefully when it receives SIGTERM, which is how such web
+// servers stop their children.
+func (s *GracefulServer) ServeFCGIStdin() error {
+	l, err := net.FileListener(os.Stdin)
+	if err != nil {
+		return err
+	}
+	s.fcgi = true
+	s.CloseOnInterrupt(syscall.SIGTERM)
+	return s.Serve(l)
+}
+
+// trackRoutine wraps a handler so that each request is counted in the WaitGroup.
+// This is needed with FastCGI, which does not report connection states.
+func (s *GracefulServer) trackRoutine(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var site string
+		if s.diagnostics {
+			site = "fcgi request from " + r.RemoteAddr
+		}
+		defer s.finishRoutine(s.startRoutine(site, ""))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveFCGI serves FastCGI on the listener. FastCGI requests carry no reference
+// to their connection, so when peers are verified, each connection is served
+// separately with a handler that adds its credentials to the request context.
+func (s *GracefulServer) serveFCGI(l net.Listener, h http.Handler) error {
+	if s.verifyPeer == nil {
+		return fcgi.Serve(l, h)
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		handler := h
+		if gc, ok := conn.(*gracefulConn); ok && gc.peerCred != nil {
+			cred := *gc.peerCred
+			handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerCredKey{}, cred)))
+			})
+		}
+		// The connection is served in its own goroutine, so this returns
+		// as soon as its listener is exhausted.
+		fcgi.Serve(&connListener{conn: conn}, handler)
+	}
+}
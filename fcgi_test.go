@@ -0,0 +1,62 @@
+package manners
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServeFCGIStdin(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := l.(*net.TCPListener).File()
+	l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = file
+	defer func() { os.Stdin = stdin }()
+
+	server := NewWithServer(&http.Server{Handler: nullHandler})
+	server.up = make(chan net.Listener)
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- server.ServeFCGIStdin()
+	}()
+	<-server.up
+	file.Close()
+
+	if !server.fcgi {
+		t.Error("Expected the server to use FastCGI")
+	}
+
+	server.Close()
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down")
+	}
+}
+
+func TestTrackRoutine(t *testing.T) {
+	server := NewServer()
+	called := false
+	handler := server.trackRoutine(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if server.active != 1 {
+			t.Errorf("Expected the request to be tracked, got %d active", server.active)
+		}
+	}))
+	handler.ServeHTTP(nil, nil)
+	if !called || server.active != 0 {
+		t.Errorf("Expected the request to be untracked after it finished, got %d active", server.active)
+	}
+}
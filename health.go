@@ -0,0 +1,74 @@
+package manners
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// A HealthCheck reports whether some dependency of the application, such as a
+// database or cache, is usable. It returns nil if so.
+type HealthCheck func(context.Context) error
+
+type healthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// AddHealthCheck registers a named health check that is run by the ReadinessHandler.
+func (s *GracefulServer) AddHealthCheck(name string, check HealthCheck) {
+	s.healthMutex.Lock()
+	defer s.healthMutex.Unlock()
+	s.healthChecks = append(s.healthChecks, healthCheck{name, check})
+}
+
+// ReadinessHandler returns a handler suitable for a load balancer's or orchestrator's
+// readiness probe. It responds 200 OK if all the registered health checks pass, and
+// 503 Service Unavailable otherwise, listing the result of each check. Once shutdown
+// has started, it always responds 503, whatever the checks report. The address the
+// server is listening on is given in the X-Listen-Address response header.
+//
+// The handler is typically registered on the same server, eg.
+//
+//	mux.Handle("/ready", s.ReadinessHandler())
+func (s *GracefulServer) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		if addr := s.ListenAddr(); addr != "" {
+			w.Header().Set("X-Listen-Address", addr)
+		}
+
+		if atomic.LoadInt32(&s.phase) != phaseServing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, phaseNames[atomic.LoadInt32(&s.phase)])
+			return
+		}
+
+		s.healthMutex.RLock()
+		checks := s.healthChecks
+		s.healthMutex.RUnlock()
+
+		status := http.StatusOK
+		results := make([]string, len(checks))
+		for i, hc := range checks {
+			if err := hc.check(r.Context()); err != nil {
+				status = http.StatusServiceUnavailable
+				results[i] = fmt.Sprintf("%s: %v", hc.name, err)
+			} else {
+				results[i] = hc.name + ": ok"
+			}
+		}
+
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			fmt.Fprintln(w, "ready")
+		} else {
+			fmt.Fprintln(w, "not ready")
+		}
+		for _, result := range results {
+			fmt.Fprintln(w, result)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package manners
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), DrainDelay: 100 * time.Millisecond})
+	server.Handler = server.ReadinessHandler()
+	server.AddHealthCheck("db", func(ctx context.Context) error { return nil })
+
+	client, errc := server.ServeInMemory()
+
+	get := func() (int, string) {
+		resp, err := client.Get("http://manners/ready")
+		if err != nil {
+			t.Fatal("Get failed", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get(); code != http.StatusOK || body != "ready\ndb: ok\n" {
+		t.Errorf("Unexpected readiness %d %q", code, body)
+	}
+
+	server.AddHealthCheck("cache", func(ctx context.Context) error { return errors.New("unreachable") })
+	if code, body := get(); code != http.StatusServiceUnavailable || !strings.Contains(body, "cache: unreachable") {
+		t.Errorf("Unexpected readiness %d %q", code, body)
+	}
+
+	server.Close()
+	if code, body := get(); code != http.StatusServiceUnavailable || body != "draining\n" {
+		t.Errorf("Unexpected readiness during drain delay %d %q", code, body)
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
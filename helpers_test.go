@@ -77,7 +77,9 @@ var nullHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request)
 
 func startGenericServer(t *testing.T, server *GracefulServer, statechanged chan http.ConnState, runner func() error) (l net.Listener, errc chan error) {
 	server.Addr = "localhost:0"
-	server.Handler = nullHandler
+	if server.Handler == nil {
+		server.Handler = nullHandler
+	}
 	if statechanged != nil {
 		// Wrap the ConnState handler with something that will notify
 		// the statechanged channel when a state change happens
@@ -0,0 +1,105 @@
+package manners
+
+import "context"
+
+// A HijackPolicy determines how hijacked connections, such as websockets, are
+// treated when the server shuts down.
+type HijackPolicy int
+
+const (
+	// HijackIgnore stops counting connections once they are hijacked, so that
+	// they do not delay the drain. This is the default. Use RegisterOnShutdown
+	// to tell them to close.
+	HijackIgnore HijackPolicy = iota
+
+	// HijackWait counts hijacked connections as active until they are closed,
+	// so that the drain waits for them. Stop, the DrainTimeout and the
+	// DrainRequestLimit close them along with all other connections.
+	HijackWait
+
+	// HijackDrain hands hijacked connections to the subsystem drain, so that
+	// they do not delay the requests but are drained like a Drainable added
+	// before all the others: once the requests have completed, the drain waits
+	// for them to close until the DrainTimeout expires, and then closes them.
+	// Use RegisterOnShutdown to tell them to close. Stop closes them at once.
+	HijackDrain
+)
+
+// trackHijacked records a hijacked connection until it is closed. With
+// HijackWait, it remains counted in the WaitGroup until then.
+func (s *GracefulServer) trackHijacked(g *gracefulConn) {
+	g.hijackedBy = s
+	s.connsMutex.Lock()
+	if s.hijacked == nil {
+		s.hijacked = make(map[*gracefulConn]struct{})
+	}
+	s.hijacked[g] = struct{}{}
+	s.connsMutex.Unlock()
+}
+
+// releaseHijacked stops recording, and counting, a hijacked connection. It is
+// idempotent.
+func (s *GracefulServer) releaseHijacked(g *gracefulConn) {
+	s.connsMutex.Lock()
+	_, ok := s.hijacked[g]
+	delete(s.hijacked, g)
+	s.connsMutex.Unlock()
+	if !ok {
+		return
+	}
+	if s.hijackPolicy == HijackWait {
+		s.finishRoutine(g.routine)
+		g.routine = nil
+	}
+	select {
+	case s.hijackedClosed <- struct{}{}:
+	default:
+	}
+}
+
+// closeHijacked closes all the hijacked connections that are being recorded.
+func (s *GracefulServer) closeHijacked() {
+	s.connsMutex.Lock()
+	conns := make([]*gracefulConn, 0, len(s.hijacked))
+	for g := range s.hijacked {
+		conns = append(conns, g)
+	}
+	s.connsMutex.Unlock()
+	for _, g := range conns {
+		g.Close()
+	}
+}
+
+// Close closes the connection and, if it was hijacked and is being recorded,
+// stops recording it.
+func (g *gracefulConn) Close() error {
+	err := g.Conn.Close()
+	if g.hijackedBy != nil {
+		g.hijackedBy.releaseHijacked(g)
+	}
+	return err
+}
+
+// hijackedConns is the Drainable for hijacked connections with HijackDrain.
+type hijackedConns struct {
+	s *GracefulServer
+}
+
+// Drain waits for the hijacked connections to close, closing any that remain
+// when ctx is done.
+func (h hijackedConns) Drain(ctx context.Context) error {
+	for {
+		h.s.connsMutex.Lock()
+		n := len(h.s.hijacked)
+		h.s.connsMutex.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-h.s.hijackedClosed:
+		case <-ctx.Done():
+			h.s.closeHijacked()
+			return ctx.Err()
+		}
+	}
+}
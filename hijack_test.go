@@ -0,0 +1,151 @@
+package manners
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func hijackingServer(o Options) (*GracefulServer, <-chan error, net.Conn) {
+	server := NewWithOptions(o)
+	hijacked := make(chan net.Conn)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _, _ := w.(http.Hijacker).Hijack()
+		hijacked <- c
+	})
+	client, errc := server.ServeInMemory()
+	go client.Get("http://manners/")
+	return server, errc, <-hijacked
+}
+
+// Tests that with HijackWait the drain waits for a hijacked connection to close.
+func TestHijackWait(t *testing.T) {
+	server, errc, conn := hijackingServer(Options{Server: new(http.Server), HijackPolicy: HijackWait})
+	server.Close()
+
+	select {
+	case <-errc:
+		t.Fatal("Server finished while a hijacked connection was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.Close()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not finish after the hijacked connection closed")
+	}
+}
+
+// Tests that with HijackWait a hijacked connection is closed when the drain
+// timeout expires.
+func TestHijackWaitTimeout(t *testing.T) {
+	server, errc, conn := hijackingServer(Options{
+		Server:       new(http.Server),
+		HijackPolicy: HijackWait,
+		DrainTimeout: 50 * time.Millisecond,
+	})
+	server.Close()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not finish after the drain timeout")
+	}
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Error("Expected the hijacked connection to be closed")
+	}
+}
+
+// Tests that by default the drain does not wait for hijacked connections.
+func TestHijackIgnore(t *testing.T) {
+	server, errc, conn := hijackingServer(Options{Server: new(http.Server)})
+	defer conn.Close()
+	server.Close()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server waited for a hijacked connection")
+	}
+}
+
+// signalDrainable is closed when it is drained.
+type signalDrainable chan struct{}
+
+func (d signalDrainable) Drain(ctx context.Context) error {
+	close(d)
+	return nil
+}
+
+// Tests that with HijackDrain a hijacked connection does not delay the
+// requests but is drained with the subsystems, which wait for it to close.
+func TestHijackDrain(t *testing.T) {
+	server, errc, conn := hijackingServer(Options{Server: new(http.Server), HijackPolicy: HijackDrain})
+	drained := make(signalDrainable)
+	server.AddDrainable(drained)
+	server.Close()
+
+	select {
+	case <-errc:
+		t.Fatal("Server finished while a hijacked connection was open")
+	case <-drained:
+		t.Fatal("Drainable ran before the hijacked connection was drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+	server.stats.mutex.Lock()
+	requestsDone := server.stats.result.RequestsDone
+	server.stats.mutex.Unlock()
+	if requestsDone.IsZero() {
+		t.Error("Expected the requests to be done while the hijacked connection was open")
+	}
+
+	conn.Close()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not finish after the hijacked connection closed")
+	}
+	select {
+	case <-drained:
+	default:
+		t.Error("Expected the Drainable to run after the hijacked connection")
+	}
+}
+
+// Tests that with HijackDrain a hijacked connection is closed when the drain
+// timeout expires.
+func TestHijackDrainTimeout(t *testing.T) {
+	server, errc, conn := hijackingServer(Options{
+		Server:       new(http.Server),
+		HijackPolicy: HijackDrain,
+		DrainTimeout: 50 * time.Millisecond,
+	})
+	server.Close()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not finish after the drain timeout")
+	}
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Error("Expected the hijacked connection to be closed")
+	}
+}
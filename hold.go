@@ -0,0 +1,85 @@
+package manners
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Hold keeps the server's shutdown open until it is released. Unlike
+// StartRoutine and FinishRoutine, each hold has a name, so that any still
+// outstanding during a drain can be identified.
+type Hold struct {
+	server  *GracefulServer
+	name    string
+	since   time.Time
+	once    sync.Once
+	routine *routineEntry
+}
+
+// HoldInfo describes an outstanding Hold.
+type HoldInfo struct {
+	Name  string
+	Since time.Time
+}
+
+// Hold prevents the server from finishing its shutdown until the returned Hold
+// is released. Use it for goroutines, such as a queue flush, that must complete
+// before the process exits:
+//
+//	h := server.Hold("kafka-flush")
+//	defer h.Release()
+func (s *GracefulServer) Hold(name string) *Hold {
+	h := &Hold{server: s, name: name, since: time.Now()}
+	var site, stack string
+	if s.diagnostics {
+		site, stack = fmt.Sprintf("hold %q at %s", name, callerSite(1)), callerStack()
+	}
+	h.routine = s.startRoutine(site, stack)
+	s.connsMutex.Lock()
+	if s.holds == nil {
+		s.holds = make(map[*Hold]struct{})
+	}
+	s.holds[h] = struct{}{}
+	s.connsMutex.Unlock()
+	return h
+}
+
+// Release allows the shutdown to proceed. Calling it more than once has no
+// further effect.
+func (h *Hold) Release() {
+	h.once.Do(func() {
+		h.server.connsMutex.Lock()
+		delete(h.server.holds, h)
+		h.server.connsMutex.Unlock()
+		h.server.finishRoutine(h.routine)
+	})
+}
+
+// Holds returns the outstanding holds, oldest first.
+func (s *GracefulServer) Holds() []HoldInfo {
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+	var infos []HoldInfo
+	for h := range s.holds {
+		infos = append(infos, HoldInfo{Name: h.name, Since: h.since})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Since.Before(infos[j].Since) })
+	return infos
+}
+
+// logHolds reports any outstanding holds, which would otherwise be hard to
+// tell apart from in-flight requests.
+func (s *GracefulServer) logHolds() {
+	holds := s.Holds()
+	if len(holds) == 0 {
+		return
+	}
+	names := make([]string, len(holds))
+	for i, h := range holds {
+		names[i] = h.Name
+	}
+	logger.Printf("Server on %s is held open by %s\n", s.ident(), strings.Join(names, ", "))
+}
@@ -0,0 +1,44 @@
+package manners
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHold(t *testing.T) {
+	server := NewWithServer(new(http.Server))
+	client, errc := server.ServeInMemory()
+	resp, err := client.Get("http://manners/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	flush := server.Hold("kafka-flush")
+	cache := server.Hold("cache-sync")
+	holds := server.Holds()
+	if len(holds) != 2 || holds[0].Name != "kafka-flush" || holds[1].Name != "cache-sync" {
+		t.Errorf("Unexpected holds %+v", holds)
+	}
+	if sim := server.SimulateDrain(); len(sim.Holds) != 2 {
+		t.Errorf("Expected the simulation to report 2 holds, got %+v", sim.Holds)
+	}
+
+	go server.Close()
+	cache.Release()
+	cache.Release()
+	select {
+	case <-errc:
+		t.Fatal("Server exited while a hold was outstanding")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if holds := server.Holds(); len(holds) != 1 || holds[0].Name != "kafka-flush" {
+		t.Errorf("Unexpected holds %+v", holds)
+	}
+
+	flush.Release()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -0,0 +1,76 @@
+package manners
+
+import (
+	"net"
+	"os"
+	"sync"
+)
+
+// ServeStdin serves HTTP on the socket passed as standard input by an inetd-style
+// supervisor, such as inetd, xinetd or launchd. This may be a listening socket, as
+// with xinetd's "wait = yes", which is served as usual; or it may be a single
+// connection, as with "wait = no", in which case the server shuts down gracefully
+// once that connection has closed.
+func (s *GracefulServer) ServeStdin() error {
+	c, err := net.FileConn(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if c.RemoteAddr() == nil {
+		// Not connected, so it is a listening socket.
+		c.Close()
+		l, err := net.FileListener(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return s.Serve(l)
+	}
+
+	l := &stdinListener{closed: make(chan struct{})}
+	l.conn = &closeNotifyConn{Conn: c, onClose: func() { go s.Close() }}
+	return s.Serve(l)
+}
+
+// stdinListener accepts a single, existing connection, then blocks until it
+// is closed.
+type stdinListener struct {
+	conn     net.Conn
+	accepted bool
+	mutex    sync.Mutex
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (l *stdinListener) Accept() (net.Conn, error) {
+	l.mutex.Lock()
+	if !l.accepted {
+		l.accepted = true
+		l.mutex.Unlock()
+		return l.conn, nil
+	}
+	l.mutex.Unlock()
+	<-l.closed
+	return nil, errPipeListenerClosed
+}
+
+func (l *stdinListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *stdinListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// closeNotifyConn calls onClose once, when the connection is first closed.
+type closeNotifyConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *closeNotifyConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.onClose)
+	return err
+}
@@ -0,0 +1,106 @@
+package manners
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// withStdin runs f with standard input replaced by the file for a socket.
+func withStdin(file *os.File, f func()) {
+	stdin := os.Stdin
+	os.Stdin = file
+	defer func() { os.Stdin = stdin }()
+	f()
+}
+
+func TestServeStdinListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	file, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	server := NewWithServer(&http.Server{Handler: nullHandler})
+	server.up = make(chan net.Listener)
+	exitchan := make(chan error)
+	withStdin(file, func() {
+		go func() {
+			exitchan <- server.ServeStdin()
+		}()
+		<-server.up
+	})
+
+	resp, err := http.Get("http://" + l.Addr().String())
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	resp.Body.Close()
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestServeStdinConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := conn.(*net.TCPConn).File()
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	server := NewWithServer(&http.Server{Handler: nullHandler})
+	server.up = make(chan net.Listener)
+	exitchan := make(chan error)
+	withStdin(file, func() {
+		go func() {
+			exitchan <- server.ServeStdin()
+		}()
+		<-server.up
+	})
+
+	req, _ := http.NewRequest("GET", "http://manners/", nil)
+	req.Close = true
+	req.Write(client)
+	resp, err := http.ReadResponse(bufio.NewReader(client), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down when its connection closed")
+	}
+}
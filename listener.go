@@ -31,6 +31,52 @@ type gracefulConn struct {
 	// protected tells whether the connection is going to defer server shutdown
 	// until the current HTTP request is completed.
 	protected bool
+	// rejected tells whether the connection was closed because a request
+	// arrived after shutdown had started.
+	rejected bool
+	// peerCred holds the credentials of a unix socket client, if verified.
+	peerCred *PeerCred
+	// created is when the connection was opened, if MaxConnAge is set.
+	created time.Time
+	// tracked tells whether the connection is registered for Connections;
+	// if so, its state and the UnixNano times of its latest reads and writes
+	// are recorded, and accessed atomically.
+	tracked   bool
+	state     int32
+	opened    int64
+	lastRead  int64
+	lastWrite int64
+	// hijackedBy is the server waiting for the connection to close after it
+	// was hijacked, if any.
+	hijackedBy *GracefulServer
+	// routine is the connection's entry for RoutineDiagnostics while it is
+	// counted in the WaitGroup.
+	routine *routineEntry
+	// onError, if not nil, is called with read and write failures.
+	onError func(*ConnError)
+	// addr is allocated along with the connection so that LocalAddr, which
+	// is called on every state change, does not allocate.
+	addr gracefulAddr
+}
+
+// connPool holds connection records for reuse. Records are only returned to
+// the pool by servers with Options.PoolConns set; otherwise it stays empty.
+var connPool = sync.Pool{
+	New: func() interface{} { return new(gracefulConn) },
+}
+
+func newGracefulConn(conn net.Conn) *gracefulConn {
+	g := connPool.Get().(*gracefulConn)
+	g.Conn = conn
+	g.addr = gracefulAddr{conn.LocalAddr(), g}
+	return g
+}
+
+// release clears the connection record and returns it to the pool. It must
+// not be used afterwards.
+func (g *gracefulConn) release() {
+	*g = gracefulConn{}
+	connPool.Put(g)
 }
 
 type gracefulAddr struct {
@@ -39,13 +85,16 @@ type gracefulAddr struct {
 }
 
 func (g *gracefulConn) LocalAddr() net.Addr {
-	return &gracefulAddr{g.Conn.LocalAddr(), g}
+	return &g.addr
 }
 
 // retrieveGracefulConn retrieves a concrete gracefulConn instance from an
 // interface value that can either refer to it directly or refer to a tls.Conn
 // instance wrapping around a gracefulConn one.
 func retrieveGracefulConn(conn net.Conn) *gracefulConn {
+	if g, ok := conn.(*gracefulConn); ok {
+		return g
+	}
 	return conn.LocalAddr().(*gracefulAddr).gconn
 }
 
@@ -53,9 +102,30 @@ func retrieveGracefulConn(conn net.Conn) *gracefulConn {
 // Accept() is called after it is gracefully closed, it returns a
 // listenerAlreadyClosed error. The GracefulServer will ignore this error.
 type GracefulListener struct {
-	listener net.Listener
-	open     bool
-	mutex    *sync.RWMutex
+	listener   net.Listener
+	open       bool
+	mutex      *sync.RWMutex
+	verifyPeer PeerVerifier
+
+	beforeAccept func()
+	afterAccept  AcceptHook
+	// ident, if not nil, identifies the server in log lines.
+	ident func() string
+}
+
+// An AcceptHook is called with the result of each Accept on the underlying
+// listener. For a successful accept, it returns the connection to serve, which
+// may be wrapped, or an error to reject it, in which case the connection is
+// closed. For a failed accept, its result is ignored. This allows custom
+// admission control, connection wrapping or accept telemetry.
+type AcceptHook func(net.Conn, error) (net.Conn, error)
+
+// name identifies the listener's server in log lines.
+func (l *GracefulListener) name() string {
+	if l.ident != nil {
+		return l.ident()
+	}
+	return l.Addr().String()
 }
 
 func (l *GracefulListener) isClosed() bool {
@@ -70,20 +140,52 @@ func (l *GracefulListener) Addr() net.Addr {
 
 // Accept implements the Accept method in the Listener interface.
 func (l *GracefulListener) Accept() (net.Conn, error) {
-	conn, err := l.listener.Accept()
-	if err != nil {
-		if l.isClosed() {
-			err = listenerAlreadyClosed{err}
+	for {
+		if l.beforeAccept != nil {
+			l.beforeAccept()
+		}
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if l.afterAccept != nil {
+				l.afterAccept(nil, err)
+			}
+			if l.isClosed() {
+				err = listenerAlreadyClosed{err}
+			}
+			return nil, err
+		}
+
+		var cred *PeerCred
+		_, isTLS := conn.(*tls.Conn)
+		if l.verifyPeer != nil && !isTLS {
+			cred, err = verifyPeer(conn, l.verifyPeer)
+			if err != nil {
+				logger.Printf("Rejected connection to %s from %v: %v\n", l.name(), conn.RemoteAddr(), err)
+				conn.Close()
+				continue
+			}
+		}
+
+		if l.afterAccept != nil {
+			wrapped, err := l.afterAccept(conn, nil)
+			if err != nil {
+				logger.Printf("Rejected connection to %s from %v: %v\n", l.name(), conn.RemoteAddr(), err)
+				conn.Close()
+				continue
+			}
+			conn = wrapped
+		}
+
+		// don't wrap connection if it's tls so we won't break
+		// http server internal logic that relies on the type
+		if _, ok := conn.(*tls.Conn); ok {
+			return conn, nil
 		}
-		return nil, err
-	}
 
-	// don't wrap connection if it's tls so we won't break
-	// http server internal logic that relies on the type
-	if _, ok := conn.(*tls.Conn); ok {
-		return conn, nil
+		gconn := newGracefulConn(conn)
+		gconn.peerCred = cred
+		return gconn, nil
 	}
-	return &gracefulConn{Conn: conn}, nil
 }
 
 // Close tells the wrapped listener to stop listening.  It is idempotent.
@@ -136,7 +238,7 @@ func (l *TLSListener) Accept() (c net.Conn, err error) {
 	if err != nil {
 		return
 	}
-	c = tls.Server(&gracefulConn{Conn: c}, l.config)
+	c = tls.Server(newGracefulConn(c), l.config)
 	return
 }
 
@@ -188,3 +290,43 @@ func getListenerFile(listener net.Listener) (*os.File, error) {
 	}
 	return nil, fmt.Errorf("Unsupported listener: %T", listener)
 }
+
+// interfaceAddr replaces the host part of addr with an address of the named
+// network interface, preferring IPv4.
+func interfaceAddr(name, addr string) (string, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	var ipv6 string
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return net.JoinHostPort(ipnet.IP.String(), port), nil
+		}
+		if ipv6 == "" {
+			ipv6 = ipnet.IP.String()
+			if ipnet.IP.IsLinkLocalUnicast() {
+				ipv6 += "%" + name
+			}
+		}
+	}
+
+	if ipv6 == "" {
+		return "", fmt.Errorf("interface %s has no addresses", name)
+	}
+	return net.JoinHostPort(ipv6, port), nil
+}
@@ -0,0 +1,114 @@
+package manners
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInterfaceAddr(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loopback string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+
+	addr, err := interfaceAddr(loopback, ":8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() || port != "8080" {
+		t.Errorf("Unexpected address %s", addr)
+	}
+
+	if _, err = interfaceAddr("no-such-interface", ":8080"); err == nil {
+		t.Error("Expected an error for an unknown interface")
+	}
+}
+
+// Tests that a list of addresses is tried in order and that the one chosen
+// is reported by the ListenerUp event, ListenAddr and the ReadinessHandler.
+func TestFallbackAddrs(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	server := NewWithServer(&http.Server{Addr: taken.Addr().String() + ", 127.0.0.1:0", Handler: nullHandler})
+	events := server.Events()
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- server.ListenAndServe()
+	}()
+
+	up := <-events
+	if up.Kind != ListenerUp || up.Addr.String() == taken.Addr().String() {
+		t.Errorf("Expected to listen on the fallback address, got %v %v", up.Kind, up.Addr)
+	}
+	if server.ListenAddr() != up.Addr.String() {
+		t.Errorf("Expected ListenAddr %v, got %q", up.Addr, server.ListenAddr())
+	}
+
+	rec := httptest.NewRecorder()
+	server.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if got := rec.Header().Get("X-Listen-Address"); got != up.Addr.String() {
+		t.Errorf("Expected readiness to report %v, got %q", up.Addr, got)
+	}
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	server = NewWithServer(&http.Server{Addr: taken.Addr().String(), Handler: nullHandler})
+	if err := server.ListenAndServe(); err == nil {
+		t.Error("Expected an error when no address is available")
+	}
+}
+
+func TestAcceptHooks(t *testing.T) {
+	var before, after, rejected int32
+	server := NewWithOptions(Options{
+		Server:       new(http.Server),
+		BeforeAccept: func() { atomic.AddInt32(&before, 1) },
+		AfterAccept: func(c net.Conn, err error) (net.Conn, error) {
+			if atomic.AddInt32(&after, 1) == 1 {
+				atomic.AddInt32(&rejected, 1)
+				return nil, errors.New("admission refused")
+			}
+			return c, err
+		},
+	})
+	server.Handler = nullHandler
+	client, errc := server.ServeInMemory()
+
+	if _, err := client.Get("http://manners/"); err == nil {
+		t.Error("Expected the first connection to be refused")
+	}
+	resp, err := client.Get("http://manners/")
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	resp.Body.Close()
+
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	if rejected != 1 || after < 2 || before < after {
+		t.Errorf("Unexpected hook calls: before=%d after=%d rejected=%d", before, after, rejected)
+	}
+}
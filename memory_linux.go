@@ -0,0 +1,20 @@
+package manners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// memoryUsage returns the resident set size of the process in bytes.
+func memoryUsage() (uint64, error) {
+	b, err := ioutil.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+	var size, resident uint64
+	if _, err = fmt.Sscan(string(b), &size, &resident); err != nil {
+		return 0, err
+	}
+	return resident * uint64(os.Getpagesize()), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package manners
+
+import "runtime"
+
+// memoryUsage returns the number of bytes of allocated heap objects; the resident set size
+// is not readily available on this platform.
+func memoryUsage() (uint64, error) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc, nil
+}
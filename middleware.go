@@ -0,0 +1,90 @@
+package manners
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// DrainingHeader is set on the responses with which Middleware refuses requests once
+// the server is draining, unless Options.DrainingResponseHeader names another header.
+const DrainingHeader = "X-Server-Draining"
+
+// Middleware returns a wrapper for handlers that refuses new requests with 503
+// Service Unavailable once shutdown has started, asking the client to close the
+// connection. Requests already in progress are unaffected. This gives correct
+// behaviour while the listener stays open during the drain delay, eg.
+//
+//	s.Handler = s.Middleware()(mux)
+func (s *GracefulServer) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.isDraining() {
+				s.refuse(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadOnlyMiddleware returns a wrapper for handlers that, once shutdown has
+// started, continues to serve GET, HEAD and OPTIONS requests but refuses those
+// with other methods, as per Middleware. This suits read-heavy APIs, which can
+// keep serving reads during the drain delay while writes are sent elsewhere.
+func (s *GracefulServer) ReadOnlyMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.isDraining() {
+				switch r.Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions:
+				default:
+					s.refuse(w)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// refuse responds 503 Service Unavailable because the server is draining.
+func (s *GracefulServer) refuse(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	if s.drainingHeader != "" {
+		w.Header().Set(s.drainingHeader, "1")
+	} else {
+		w.Header().Set(DrainingHeader, "1")
+	}
+	http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+}
+
+// isDraining reports whether shutdown has started.
+func (s *GracefulServer) isDraining() bool {
+	return atomic.LoadInt32(&s.phase) >= phaseDraining
+}
+
+type serverKey struct{}
+
+// IsDraining reports whether the server handling a request has begun shutting
+// down, given the request's context. Long computations can use this to checkpoint
+// and return partial results rather than being cut off by the drain timeout.
+// It returns false if the context does not belong to a request served over HTTP
+// by a GracefulServer.
+func IsDraining(ctx context.Context) bool {
+	s, ok := ctx.Value(serverKey{}).(*GracefulServer)
+	return ok && s.isDraining()
+}
+
+// serverContext wraps a BaseContext function so that the server is added to the
+// base context of all requests.
+func serverContext(s *GracefulServer, next func(net.Listener) context.Context) func(net.Listener) context.Context {
+	return func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if next != nil {
+			ctx = next(l)
+		}
+		return context.WithValue(ctx, serverKey{}, s)
+	}
+}
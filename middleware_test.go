@@ -0,0 +1,190 @@
+package manners
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), DrainDelay: 100 * time.Millisecond})
+	server.Handler = server.Middleware()(nullHandler)
+
+	client, errc := server.ServeInMemory()
+
+	resp, err := client.Get("http://manners/")
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(DrainingHeader) != "" {
+		t.Errorf("Unexpected response before shutdown %d %v", resp.StatusCode, resp.Header)
+	}
+
+	server.Close()
+	resp, err = client.Get("http://manners/")
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get(DrainingHeader) != "1" || !resp.Close {
+		t.Errorf("Unexpected response during drain delay %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestIsDraining(t *testing.T) {
+	if IsDraining(context.Background()) {
+		t.Error("Expected a context without a server not to be draining")
+	}
+
+	server := NewWithOptions(Options{Server: new(http.Server), DrainDelay: 100 * time.Millisecond})
+	draining := make(chan bool, 2)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draining <- IsDraining(r.Context())
+	})
+
+	client, errc := server.ServeInMemory()
+	get := func() bool {
+		resp, err := client.Get("http://manners/")
+		if err != nil {
+			t.Fatal("Get failed", err)
+		}
+		resp.Body.Close()
+		return <-draining
+	}
+
+	if get() {
+		t.Error("Expected the server not to be draining before shutdown")
+	}
+	server.Close()
+	if !get() {
+		t.Error("Expected the server to be draining after shutdown")
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestDrainingResponseHeader(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:                 new(http.Server),
+		DrainDelay:             100 * time.Millisecond,
+		DrainingResponseHeader: "X-Draining",
+	})
+	server.Handler = nullHandler
+	client, errc := server.ServeInMemory()
+
+	get := func() string {
+		resp, err := client.Get("http://manners/")
+		if err != nil {
+			t.Fatal("Get failed", err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("X-Draining")
+	}
+
+	if h := get(); h != "" {
+		t.Errorf("Unexpected header before shutdown %q", h)
+	}
+	server.Close()
+	if h := get(); h != "1" {
+		t.Errorf("Expected the draining header during the drain delay, got %q", h)
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestDrainingKeepAliveHint(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:     new(http.Server),
+		DrainDelay: 100 * time.Millisecond,
+	})
+	server.Handler = nullHandler
+	client, errc := server.ServeInMemory()
+
+	get := func() string {
+		resp, err := client.Get("http://manners/")
+		if err != nil {
+			t.Fatal("Get failed", err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Keep-Alive")
+	}
+
+	if h := get(); h != "" {
+		t.Errorf("Unexpected Keep-Alive header before shutdown %q", h)
+	}
+	server.Close()
+	if h := get(); h != "timeout=0, max=1" {
+		t.Errorf("Expected a Keep-Alive hint during the drain delay, got %q", h)
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Tests that the Keep-Alive hint, a connection-specific header, is not sent
+// on HTTP/2 responses.
+func TestDrainingKeepAliveHintHTTP2(t *testing.T) {
+	phase := int32(phaseDraining)
+	gh := newGracefulHandler(nullHandler, &drainStats{})
+	gh.phase = &phase
+
+	ts := httptest.NewUnstartedServer(gh)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Expected an HTTP/2 response, got %s", resp.Proto)
+	}
+	if h := resp.Header.Get("Keep-Alive"); h != "" {
+		t.Errorf("Unexpected Keep-Alive header on HTTP/2 %q", h)
+	}
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), DrainDelay: 100 * time.Millisecond})
+	server.Handler = server.ReadOnlyMiddleware()(nullHandler)
+	client, errc := server.ServeInMemory()
+
+	do := func(method string) int {
+		req, _ := http.NewRequest(method, "http://manners/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(method, "failed", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := do("POST"); code != http.StatusOK {
+		t.Errorf("Unexpected POST status before shutdown %d", code)
+	}
+	server.Close()
+	if code := do("GET"); code != http.StatusOK {
+		t.Errorf("Unexpected GET status during drain delay %d", code)
+	}
+	if code := do("POST"); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected POST status during drain delay %d", code)
+	}
+
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -0,0 +1,59 @@
+package manners
+
+import (
+	"context"
+	"net"
+)
+
+// PeerCred holds the credentials of the process at the other end of a unix
+// socket connection, as reported by the operating system.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+// PeerVerifier decides whether a unix socket client may connect, given its
+// credentials. Returning an error causes the connection to be closed.
+type PeerVerifier func(PeerCred) error
+
+type peerCredKey struct{}
+
+// PeerCredFromContext returns the credentials of the unix socket client that
+// sent a request, if the server was configured with a PeerVerifier. This works
+// for FastCGI as well as HTTP. Use the request's context, i.e. r.Context().
+func PeerCredFromContext(ctx context.Context) (PeerCred, bool) {
+	cred, ok := ctx.Value(peerCredKey{}).(PeerCred)
+	return cred, ok
+}
+
+// verifyPeer checks the credentials of unix socket connections; other
+// connections are accepted unchanged.
+func verifyPeer(conn net.Conn, verifier PeerVerifier) (*PeerCred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, nil
+	}
+	cred, err := peerCred(uc)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifier(cred); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// peerCredContext wraps a ConnContext function so that the peer credentials,
+// if any, are added to the connection's context.
+func peerCredContext(next func(context.Context, net.Conn) context.Context) func(context.Context, net.Conn) context.Context {
+	return func(ctx context.Context, c net.Conn) context.Context {
+		if gc, ok := c.(*gracefulConn); ok && gc.peerCred != nil {
+			ctx = context.WithValue(ctx, peerCredKey{}, *gc.peerCred)
+		}
+		if next != nil {
+			ctx = next(ctx, c)
+		}
+		return ctx
+	}
+}
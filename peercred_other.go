@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package manners
+
+import (
+	"errors"
+	"net"
+)
+
+func peerCred(conn *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, errors.New("peer credentials are not supported on this platform")
+}
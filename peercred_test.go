@@ -0,0 +1,170 @@
+package manners
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyPeer(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, allow := range []bool{true, false} {
+		path := filepath.Join(dir, "admin.sock")
+		l, err := listenToUnix(path)
+		if err != nil {
+			t.Fatal("Failed to create listener", err)
+		}
+
+		var seen PeerCred
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen, _ = PeerCredFromContext(r.Context())
+		})
+		server := NewWithOptions(Options{
+			Server: &http.Server{Handler: handler},
+			VerifyPeer: func(cred PeerCred) error {
+				if !allow {
+					return errors.New("not allowed")
+				}
+				return nil
+			},
+		})
+
+		errc := make(chan error, 1)
+		go func() {
+			errc <- server.Serve(l)
+		}()
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		}}
+		_, err = client.Get("http://manners/")
+		if allow {
+			if err != nil {
+				t.Fatal("Get failed", err)
+			}
+			if seen.UID != os.Getuid() || seen.PID != os.Getpid() {
+				t.Errorf("Unexpected credentials %+v", seen)
+			}
+		} else if err == nil {
+			t.Error("Get should have failed for a rejected peer")
+		}
+
+		server.Close()
+		if err := <-errc; err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	}
+}
+
+// Tests that the peer credentials are available to FastCGI handlers.
+func TestVerifyPeerFCGI(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fcgi.sock")
+
+	seen := make(chan bool, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := PeerCredFromContext(r.Context())
+		seen <- ok && cred.UID == os.Getuid() && cred.PID == os.Getpid()
+	})
+	server := NewWithOptions(Options{
+		Server:     &http.Server{Addr: path, Handler: handler},
+		VerifyPeer: func(cred PeerCred) error { return nil },
+	})
+	server.up = make(chan net.Listener)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	<-server.up
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = fcgiGet(conn, "/"); err != nil {
+		t.Fatal("FastCGI request failed", err)
+	}
+	if !<-seen {
+		t.Error("Expected the peer credentials in the FastCGI request context")
+	}
+
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// fcgiGet sends a minimal FastCGI GET request on conn and reads until the
+// request ends.
+func fcgiGet(conn net.Conn, uri string) error {
+	const (
+		typeBeginRequest = 1
+		typeEndRequest   = 3
+		typeParams       = 4
+		typeStdin        = 5
+	)
+	record := func(recType uint8, content []byte) []byte {
+		header := []byte{1, recType, 0, 1, 0, 0, 0, 0}
+		binary.BigEndian.PutUint16(header[4:], uint16(len(content)))
+		return append(header, content...)
+	}
+
+	var params bytes.Buffer
+	for _, kv := range [][2]string{{"REQUEST_METHOD", "GET"}, {"SERVER_PROTOCOL", "HTTP/1.1"}, {"REQUEST_URI", uri}} {
+		params.WriteByte(byte(len(kv[0])))
+		params.WriteByte(byte(len(kv[1])))
+		params.WriteString(kv[0] + kv[1])
+	}
+
+	var req bytes.Buffer
+	req.Write(record(typeBeginRequest, []byte{0, 1, 0, 0, 0, 0, 0, 0}))
+	req.Write(record(typeParams, params.Bytes()))
+	req.Write(record(typeParams, nil))
+	req.Write(record(typeStdin, nil))
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint16(header[4:])) + int(header[6])
+		if _, err := io.CopyN(ioutil.Discard, conn, int64(n)); err != nil {
+			return err
+		}
+		if header[1] == typeEndRequest {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package manners
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// A PipeListener is an in-memory net.Listener. Its connections are created by
+// calling Dial and are backed by net.Pipe, so no network ports are bound. This
+// allows tests to exercise the full graceful serving path hermetically.
+type PipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewPipeListener creates an in-memory listener.
+func NewPipeListener() *PipeListener {
+	return &PipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+var errPipeListenerClosed = errors.New("pipe listener closed")
+
+// Accept waits for and returns the next connection created by Dial.
+func (l *PipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, errPipeListenerClosed
+	}
+}
+
+// Close stops the listener. Subsequent calls to Accept and Dial fail. It is idempotent.
+func (l *PipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr returns a placeholder address.
+func (l *PipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// Dial creates a new in-memory connection to the listener.
+func (l *PipeListener) Dial() (net.Conn, error) {
+	return l.DialContext(context.Background())
+}
+
+// DialContext creates a new in-memory connection to the listener, waiting
+// until it has been accepted or the context is done.
+func (l *PipeListener) DialContext(ctx context.Context) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		server.Close()
+		client.Close()
+		return nil, errPipeListenerClosed
+	case <-ctx.Done():
+		server.Close()
+		client.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Client returns an http.Client whose requests are all sent to this listener,
+// whatever host the request URL specifies.
+func (l *PipeListener) Client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return l.DialContext(ctx)
+			},
+		},
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// ServeInMemory serves the server on a new PipeListener in a separate goroutine.
+// It returns a client connected to the listener and a channel that receives the
+// result of Serve once the server has shut down.
+func (s *GracefulServer) ServeInMemory() (*http.Client, <-chan error) {
+	l := NewPipeListener()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(l)
+	}()
+	return l.Client(), errc
+}
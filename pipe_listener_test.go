@@ -0,0 +1,31 @@
+package manners
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestServeInMemory(t *testing.T) {
+	server := NewServer()
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello\n"))
+	})
+
+	client, errc := server.ServeInMemory()
+
+	resp, err := client.Get("http://manners/")
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "Hello\n" {
+		t.Errorf("Unexpected response %q, %v", body, err)
+	}
+
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -0,0 +1,85 @@
+package manners
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// A ShutdownPlan shuts down the parts of an application in a declared order, eg.
+// draining the HTTP server before closing the database pool it uses, and closing
+// the metrics server last:
+//
+//	plan := manners.NewShutdownPlan().
+//		ThenServer("api", api).
+//		ThenClose("db", db).
+//		ThenServer("metrics", metrics)
+//	...
+//	for _, r := range plan.Execute(ctx) { ... }
+type ShutdownPlan struct {
+	stages []planStage
+}
+
+type planStage struct {
+	name string
+	run  func(context.Context) error
+}
+
+// A StageResult reports how one stage of a ShutdownPlan went.
+type StageResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// NewShutdownPlan creates an empty plan.
+func NewShutdownPlan() *ShutdownPlan {
+	return &ShutdownPlan{}
+}
+
+// Then adds a stage that calls f, after all the stages already added.
+func (p *ShutdownPlan) Then(name string, f func(context.Context) error) *ShutdownPlan {
+	p.stages = append(p.stages, planStage{name, f})
+	return p
+}
+
+// ThenServer adds a stage that shuts the server down and waits for it to drain, as
+// per Shutdown.
+func (p *ShutdownPlan) ThenServer(name string, s *GracefulServer) *ShutdownPlan {
+	return p.Then(name, s.Shutdown)
+}
+
+// ThenDrain adds a stage that drains a subsystem.
+func (p *ShutdownPlan) ThenDrain(name string, d Drainable) *ShutdownPlan {
+	return p.Then(name, d.Drain)
+}
+
+// ThenClose adds a stage that closes c, eg. a database pool.
+func (p *ShutdownPlan) ThenClose(name string, c io.Closer) *ShutdownPlan {
+	return p.Then(name, func(context.Context) error {
+		return c.Close()
+	})
+}
+
+// Execute runs the stages in order and reports the outcome of each. A stage that
+// fails does not prevent the later stages from running. Once ctx is done, the
+// remaining stages are skipped and report the context's error.
+func (p *ShutdownPlan) Execute(ctx context.Context) []StageResult {
+	results := make([]StageResult, len(p.stages))
+	for i, stage := range p.stages {
+		results[i].Name = stage.name
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		start := time.Now()
+		results[i].Err = stage.run(ctx)
+		results[i].Duration = time.Since(start)
+		if results[i].Err != nil {
+			logger.Printf("Shutdown stage %s failed after %v: %v\n", stage.name, results[i].Duration, results[i].Err)
+		} else {
+			logger.Printf("Shutdown stage %s finished in %v\n", stage.name, results[i].Duration)
+		}
+	}
+	return results
+}
@@ -0,0 +1,60 @@
+package manners
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestShutdownPlan(t *testing.T) {
+	server := NewServer()
+	server.Handler = nullHandler
+	client, errc := server.ServeInMemory()
+	resp, err := client.Get("http://manners/")
+	if err != nil {
+		t.Fatal("Get failed", err)
+	}
+	resp.Body.Close()
+
+	var order []string
+	plan := NewShutdownPlan().
+		ThenServer("api", server).
+		ThenClose("db", closerFunc(func() error {
+			if server.DrainResult() == nil {
+				t.Error("Expected the server to have drained before the db closed")
+			}
+			order = append(order, "db")
+			return errors.New("already closed")
+		})).
+		Then("metrics", func(context.Context) error {
+			order = append(order, "metrics")
+			return nil
+		})
+
+	results := plan.Execute(context.Background())
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	if len(order) != 2 || order[0] != "db" || order[1] != "metrics" {
+		t.Errorf("Unexpected order %v", order)
+	}
+	if len(results) != 3 || results[0].Name != "api" || results[0].Err != nil ||
+		results[1].Err == nil || results[2].Err != nil {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = NewShutdownPlan().Then("skipped", func(context.Context) error {
+		t.Error("Expected the stage to be skipped")
+		return nil
+	}).Execute(ctx)
+	if results[0].Err != context.Canceled {
+		t.Errorf("Expected the skipped stage to report cancellation, got %v", results[0].Err)
+	}
+}
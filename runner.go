@@ -0,0 +1,137 @@
+package manners
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Exit codes returned by Runner.Run.
+const (
+	// ExitOK means the servers shut down cleanly.
+	ExitOK = 0
+	// ExitError means a server failed.
+	ExitError = 1
+	// ExitDrainTimeout means the servers did not drain within the drain timeout,
+	// or another signal was received while they were draining, and their remaining
+	// connections were closed.
+	ExitDrainTimeout = 2
+)
+
+// A Runner owns one or more servers. It runs them until the process receives a
+// shutdown signal or a server fails, then drains them all within a bounded time.
+// It is a batteries-included helper for the main function of small services, eg.
+//
+//	func main() {
+//		api := manners.NewWithServer(&http.Server{Addr: ":8080", Handler: apiHandler})
+//		admin := manners.NewWithServer(&http.Server{Addr: ":9090", Handler: adminHandler})
+//		os.Exit(manners.NewRunner(api, admin).Run())
+//	}
+type Runner struct {
+	servers []*GracefulServer
+
+	// Signals are the OS signals that start the shutdown. If empty, SIGINT and
+	// SIGTERM are used.
+	Signals []os.Signal
+
+	// DrainTimeout bounds how long the servers may take to drain. If it is zero,
+	// Run waits indefinitely.
+	DrainTimeout time.Duration
+}
+
+// NewRunner creates a Runner for the given servers, with a 30 second drain timeout.
+func NewRunner(servers ...*GracefulServer) *Runner {
+	return &Runner{
+		servers:      servers,
+		DrainTimeout: 30 * time.Second,
+	}
+}
+
+type runResult struct {
+	server *GracefulServer
+	err    error
+}
+
+// Run starts the servers and blocks until they have all finished. Servers whose
+// TLSConfig provides certificates are served using TLS. The result is one of the
+// exit codes ExitOK, ExitError or ExitDrainTimeout, suitable for os.Exit.
+func (r *Runner) Run() int {
+	signals := r.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, signals...)
+	defer signal.Stop(sigchan)
+
+	results := make(chan runResult, len(r.servers))
+	for _, s := range r.servers {
+		go func(s *GracefulServer) {
+			results <- runResult{s, serveServer(s)}
+		}(s)
+	}
+
+	code := ExitOK
+	running := make(map[*GracefulServer]bool)
+	for _, s := range r.servers {
+		running[s] = true
+	}
+
+	record := func(rr runResult) {
+		delete(running, rr.server)
+		if rr.err != nil {
+			logger.Printf("Server on %s failed: %v\n", rr.server.ident(), rr.err)
+			code = ExitError
+		}
+	}
+
+	select {
+	case sig := <-sigchan:
+		logger.Printf("Received %v; shutting down\n", sig)
+	case rr := <-results:
+		record(rr)
+	}
+
+	for s := range running {
+		go s.Close()
+	}
+
+	var timeout <-chan time.Time
+	if r.DrainTimeout > 0 {
+		timer := time.NewTimer(r.DrainTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	stop := func() int {
+		for s := range running {
+			s.Stop()
+		}
+		for len(running) > 0 {
+			record(<-results)
+		}
+		return ExitDrainTimeout
+	}
+
+	for len(running) > 0 {
+		select {
+		case rr := <-results:
+			record(rr)
+		case sig := <-sigchan:
+			logger.Printf("Received %v while draining; stopping servers\n", sig)
+			return stop()
+		case <-timeout:
+			logger.Printf("Servers did not drain within %v; stopping them\n", r.DrainTimeout)
+			return stop()
+		}
+	}
+	return code
+}
+
+func serveServer(s *GracefulServer) error {
+	if s.TLSConfig != nil && (len(s.TLSConfig.Certificates) > 0 || s.TLSConfig.GetCertificate != nil) {
+		return s.ListenAndServeTLSWithConfig(s.TLSConfig)
+	}
+	return s.ListenAndServe()
+}
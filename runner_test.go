@@ -0,0 +1,92 @@
+package manners
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunnerSignal(t *testing.T) {
+	s1 := NewWithServer(&http.Server{Addr: "localhost:0", Handler: nullHandler})
+	s2 := NewWithServer(&http.Server{Addr: "localhost:0", Handler: nullHandler})
+	s1.up = make(chan net.Listener)
+	s2.up = make(chan net.Listener)
+	runner := NewRunner(s1, s2)
+	runner.Signals = []os.Signal{syscall.SIGUSR2}
+
+	code := runAsync(runner)
+	<-s1.up
+	<-s2.up
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+
+	select {
+	case c := <-code:
+		if c != ExitOK {
+			t.Errorf("Expected exit code %d, got %d", ExitOK, c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Runner did not finish")
+	}
+}
+
+func TestRunnerServerError(t *testing.T) {
+	s1 := NewWithServer(&http.Server{Addr: "localhost:0", Handler: nullHandler})
+	s2 := NewWithServer(&http.Server{Addr: "no-port", Handler: nullHandler})
+
+	select {
+	case c := <-runAsync(NewRunner(s1, s2)):
+		if c != ExitError {
+			t.Errorf("Expected exit code %d, got %d", ExitError, c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Runner did not finish")
+	}
+}
+
+func runAsync(r *Runner) <-chan int {
+	code := make(chan int, 1)
+	go func() {
+		code <- r.Run()
+	}()
+	return code
+}
+
+func TestRunnerSecondSignal(t *testing.T) {
+	started := make(chan bool)
+	s1 := NewWithServer(&http.Server{Addr: "localhost:0", Handler: blockingHandler(started)})
+	s1.up = make(chan net.Listener)
+	runner := NewRunner(s1)
+	runner.Signals = []os.Signal{syscall.SIGUSR2}
+
+	code := runAsync(runner)
+	l := <-s1.up
+	go http.Get("http://" + l.Addr().String())
+	<-started
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+	for !s1.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+
+	select {
+	case c := <-code:
+		if c != ExitDrainTimeout {
+			t.Errorf("Expected exit code %d, got %d", ExitDrainTimeout, c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Runner did not stop on the second signal")
+	}
+}
+
+// blockingHandler signals when a request has started, then blocks until its
+// connection is closed.
+func blockingHandler(started chan bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-r.Context().Done()
+	})
+}
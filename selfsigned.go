@@ -0,0 +1,128 @@
+package manners
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// GenerateSelfSignedCert creates a PEM-encoded self-signed certificate and private key
+// that are valid for one year for the given host names and IP addresses. If no hosts
+// are given, "localhost", "127.0.0.1" and "::1" are used. The certificate is only
+// suitable for development and testing.
+func GenerateSelfSignedCert(hosts ...string) (certPEM, keyPEM []byte, err error) {
+	return generateSelfSignedCert(time.Now().Add(-time.Hour), hosts...)
+}
+
+var errSelfSignedPaths = errors.New("both or neither of certFile and keyFile must be given")
+
+func generateSelfSignedCert(notBefore time.Time, hosts ...string) (certPEM, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Manners self-signed"}},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// ListenAndServeTLSSelfSigned is similar to ListenAndServeTLS except that it uses a
+// self-signed certificate, removing the need to create certificate files when developing
+// locally. If certFile and keyFile are empty, the certificate is generated in memory on
+// every call. Otherwise, the certificate is loaded from these files if they exist, or
+// generated and saved to them if they do not, so that it can be trusted once by the
+// browser and reused. An expired certificate is replaced by a new one. It is an error
+// to give only one of certFile and keyFile.
+func (s *GracefulServer) ListenAndServeTLSSelfSigned(certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return errSelfSignedPaths
+	}
+
+	if certFile != "" {
+		if _, err := os.Stat(certFile); err == nil {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return err
+			}
+			if !certExpired(cert) {
+				return s.listenAndServeTLSWithCertificate(cert)
+			}
+			logger.Printf("Self-signed certificate %s for %s has expired\n", certFile, s.ident())
+		}
+	}
+
+	certPEM, keyPEM, err := GenerateSelfSignedCert()
+	if err != nil {
+		return err
+	}
+	logger.Printf("Generated self-signed certificate for %s\n", s.ident())
+
+	if certFile != "" {
+		if err = ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return err
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	return s.listenAndServeTLSWithCertificate(cert)
+}
+
+// certExpired reports whether the certificate's validity period has ended.
+func certExpired(cert tls.Certificate) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	return err == nil && time.Now().After(leaf.NotAfter)
+}
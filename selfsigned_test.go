@@ -0,0 +1,108 @@
+package manners
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	certPEM, keyPEM, err := GenerateSelfSignedCert("example.com", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal("Generated certificate is not usable", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = leaf.VerifyHostname("example.com"); err != nil {
+		t.Error(err)
+	}
+	if err = leaf.VerifyHostname("10.0.0.1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListenAndServeTLSSelfSigned(t *testing.T) {
+	server := NewServer()
+	runner := func() error {
+		return server.ListenAndServeTLSSelfSigned("", "")
+	}
+	listener, exitchan := startGenericServer(t, server, nil, runner)
+
+	client := newClient(listener.Addr(), true)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	if rr := <-client.response; rr.err != nil {
+		t.Fatal("Unexpected error from client", rr.err)
+	}
+	close(client.sendrequest)
+	<-client.closed
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestListenAndServeTLSSelfSignedExpired(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	certPEM, keyPEM, err := generateSelfSignedCert(time.Now().AddDate(-2, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer()
+	runner := func() error {
+		return server.ListenAndServeTLSSelfSigned(certFile, keyFile)
+	}
+	_, exitchan := startGenericServer(t, server, nil, runner)
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certExpired(cert) {
+		t.Error("Expected the expired certificate to be regenerated")
+	}
+}
+
+func TestListenAndServeTLSSelfSignedOnePath(t *testing.T) {
+	server := NewServer()
+	if err := server.ListenAndServeTLSSelfSigned("cert.pem", ""); err != errSelfSignedPaths {
+		t.Errorf("Expected %v, got %v", errSelfSignedPaths, err)
+	}
+	if err := server.ListenAndServeTLSSelfSigned("", "key.pem"); err != errSelfSignedPaths {
+		t.Errorf("Expected %v, got %v", errSelfSignedPaths, err)
+	}
+}
@@ -0,0 +1,54 @@
+package manners
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var errNotServing = errors.New("server is not serving")
+
+// ServeConn serves HTTP on a connection accepted elsewhere, eg. by a custom accept
+// loop or a connection broker. The connection is tracked in the same way as those
+// accepted by the server's own listener, so it takes part in the drain. ServeConn
+// returns once the connection has been handed over; it is served in a separate
+// goroutine. The connection carries plain HTTP, whatever the server's own listener
+// uses. The server must already be serving HTTP via Serve or one of the
+// ListenAndServe methods; otherwise, or once shutdown has started, the connection
+// is closed and an error is returned.
+func (s *GracefulServer) ServeConn(c net.Conn) error {
+	if atomic.LoadInt32(&s.phase) != phaseServing {
+		c.Close()
+		return errNotServing
+	}
+	err := s.Server.Serve(&connListener{conn: newGracefulConn(c)})
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// connListener is a listener that accepts a single, existing connection.
+type connListener struct {
+	once sync.Once
+	conn net.Conn
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var c net.Conn
+	l.once.Do(func() { c = l.conn })
+	if c == nil {
+		return nil, io.EOF
+	}
+	return c, nil
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
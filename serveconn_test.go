@@ -0,0 +1,40 @@
+package manners
+
+import (
+	"bufio"
+	helpers "github.com/rickb777/manners/test_helpers"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestServeConn(t *testing.T) {
+	server := NewServer()
+	if err := server.ServeConn(&helpers.Conn{}); err != errNotServing {
+		t.Errorf("Expected errNotServing before serving, got %v", err)
+	}
+
+	_, exitchan := startServer(t, server, nil)
+
+	serverConn, clientConn := net.Pipe()
+	if err := server.ServeConn(serverConn); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://manners/", nil)
+	go req.Write(clientConn)
+	resp, err := http.ReadResponse(bufio.NewReader(clientConn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+
+	server.Close()
+	clientConn.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
@@ -30,21 +30,33 @@ The server will shut down cleanly when the Close() method is called:
 	manners.CloseOnInterrupt()
 	http.Handle("/hello", myHandler)
 	log.Fatal(manners.ListenAndServe(":8080", nil))
+
+Like net/http, the package provides ListenAndServe, ListenAndServeTLS, Serve and ServeTLS
+functions that use a default server. Each honours CloseOnInterrupt and is stopped by Close:
+
+	manners.CloseOnInterrupt()
+	l, err := net.Listen("tcp", ":8443")
+	...
+	log.Fatal(manners.ServeTLS(l, myHandler, "cert.pem", "key.pem"))
 */
 package manners
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net"
 	"net/http"
-	"net/http/fcgi"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 // StateHandler can be called by the server if the state of the connection changes.
@@ -55,6 +67,160 @@ type Options struct {
 	Server       *http.Server
 	StateHandler StateHandler
 	Listener     net.Listener
+
+	// Name, if not empty, identifies the server in log lines, events and
+	// webhooks, along with its listener address. This distinguishes the
+	// servers in a process that serves several listeners.
+	Name string
+
+	// ControlSocket, if not empty, is the path of a unix socket on which
+	// the server accepts control commands. See ServeControl.
+	ControlSocket string
+
+	// AgentCheckAddr, if not empty, is the TCP address on which the server
+	// reports its state to HAProxy's agent-check. See ServeAgentCheck.
+	AgentCheckAddr string
+
+	// DrainDelay, if positive, is how long the server keeps accepting
+	// requests after shutdown starts, while its ReadinessHandler reports that
+	// it is not ready. This gives load balancers time to stop routing to it.
+	DrainDelay time.Duration
+
+	// Deregister, if not nil, is called when shutdown starts, before the
+	// drain delay, to remove the server from its load balancer, eg. an AWS
+	// ALB or NLB target group. DrainDelay should then be set to the target
+	// group's deregistration delay, so that the listener is only closed once
+	// the load balancer has stopped sending requests. An error is logged but
+	// does not prevent the drain.
+	Deregister func(context.Context) error
+
+	// DrainTimeout, if positive, limits how long the server waits for
+	// in-flight requests after shutdown starts. When it expires, all
+	// remaining connections are closed as per Stop.
+	DrainTimeout time.Duration
+
+	// StreamingGrace, if positive, extends the DrainTimeout for connections
+	// that are actively streaming a response, such as a file download or
+	// event stream, so that they are not truncated. When the drain timeout
+	// expires, connections that have been written to within the last second
+	// are given this much longer; the others are closed immediately.
+	StreamingGrace time.Duration
+
+	// DrainSilenceLimit, if positive, closes connections during the drain
+	// once nothing has been read from or written to them for this long, so
+	// that stalled requests do not hold up the drain until the DrainTimeout.
+	DrainSilenceLimit time.Duration
+
+	// DrainProgressInterval is how often, during the drain, the remaining
+	// requests and holds are logged and silent connections are looked for.
+	// It defaults to one second. Servers with very many connections may want
+	// less frequent reports; latency-sensitive ones may want quicker checks.
+	DrainProgressInterval time.Duration
+
+	// OnConnError, if not nil, is called with connection-level failures:
+	// TLS handshake errors, requests aborted by clients and failed writes.
+	// These are otherwise only visible in the http.Server's ErrorLog, if at
+	// all. It is called from the connection's goroutine, so it must not block.
+	OnConnError func(*ConnError)
+
+	// HijackPolicy determines whether the drain waits for hijacked
+	// connections, either with the requests or with the Drainables. By
+	// default, it does not.
+	HijackPolicy HijackPolicy
+
+	// TrackConnections records the open connections and the time of their
+	// latest reads and writes, making them available via Connections. This
+	// is implied by StreamingGrace and DrainSilenceLimit.
+	TrackConnections bool
+
+	// RoutineDiagnostics records where each outstanding routine was started,
+	// making the sites available via RoutineSites, and logs them while
+	// draining. FinishRoutine panics, naming its caller, if it is called
+	// more often than StartRoutine. This slows every connection, so it is
+	// intended for finding why a server never finishes shutting down.
+	RoutineDiagnostics bool
+
+	// DrainRequestLimit, if positive, is the number of requests that may
+	// complete after shutdown starts. Once that many have completed, all
+	// remaining connections are closed as per Stop.
+	DrainRequestLimit int
+
+	// DrainLock, if not nil, is held while the server drains, so that at most
+	// one of the servers sharing it drains at a time. Shutdown waits until the
+	// lock is available before the drain delay starts; until then, the server
+	// continues to report that it is ready.
+	DrainLock DrainLock
+
+	// MaxConnAge, if positive, is the age beyond which a keep-alive connection
+	// is closed after its next response. Long-lived clients then reconnect
+	// periodically, rebalancing across instances, and drains need not wait
+	// on very old connections.
+	MaxConnAge time.Duration
+
+	// DrainingResponseHeader, if not empty, is the name of a header set to "1"
+	// on the responses to all requests that start once shutdown has started,
+	// so that clients and service meshes can reconnect elsewhere before the
+	// connection is closed. DrainingHeader is a conventional name. It is also
+	// used by Middleware instead of DrainingHeader.
+	DrainingResponseHeader string
+
+	// DrainWebhooks are URLs to which a JSON WebhookPayload is POSTed when the
+	// drain starts and when it is complete, so that orchestrators and chatops
+	// are notified without scraping logs. The completion notification includes
+	// the DrainResult; Serve waits briefly for it to be delivered.
+	DrainWebhooks []string
+
+	// Tracer, if not nil, is used to trace the shutdown sequence: a span for
+	// each stage, and one for the RegisterOnShutdown functions that ends
+	// when they have all returned.
+	Tracer Tracer
+
+	// PoolConns enables reuse of the server's per-connection records once
+	// connections have closed, reducing garbage collection pressure on
+	// servers handling very many short connections. When it is set, the
+	// net.Conn passed to a StateHandler or ConnState function, and the local
+	// address in a request's context, must not be used after the connection
+	// has closed.
+	PoolConns bool
+
+	// VerifyPeer, if not nil, is called with the credentials of each client
+	// connecting via a unix socket; the connection is closed if it returns an
+	// error. The credentials are also available to HTTP and FastCGI handlers
+	// via PeerCredFromContext. This is only supported on Linux.
+	VerifyPeer PeerVerifier
+
+	// BeforeAccept, if not nil, is called before each Accept on the
+	// listener, and AfterAccept with its result; see AcceptHook.
+	BeforeAccept func()
+	AfterAccept  AcceptHook
+
+	// TLSKeyLogWriter, if not nil, receives the TLS session keys in NSS key
+	// log format, allowing tools such as Wireshark to decrypt the traffic.
+	// This destroys the security of TLS, so UnsafeTLSKeyLog must also be set
+	// to confirm that it is intended; otherwise ListenAndServeTLS fails.
+	TLSKeyLogWriter io.Writer
+	UnsafeTLSKeyLog bool
+
+	// TLSMinVersion, TLSCipherSuites and TLSCurvePreferences harden the TLS
+	// listener without needing a complete TLSConfig. They override the
+	// corresponding TLSConfig fields. If neither sets them, the minimum
+	// version is TLS 1.2 and the cipher suites are DefaultTLSCipherSuites;
+	// the curve preferences are left to crypto/tls, whose defaults are secure.
+	TLSMinVersion       uint16
+	TLSCipherSuites     []uint16
+	TLSCurvePreferences []tls.CurveID
+
+	// DeferAccept asks the kernel to wake the accept loop only once data has
+	// arrived on a new TCP connection, reducing wasted wakeups from idle or
+	// probing connections. This uses TCP_DEFER_ACCEPT on Linux and the
+	// "dataready" accept filter on the BSDs (where the accf_data kernel module
+	// must be loaded); it is ignored on other platforms.
+	DeferAccept bool
+
+	// Interface, if not empty, is the name of the network interface (e.g.
+	// "eth1") to listen on. The host part of Addr is replaced by the first
+	// IPv4 address of the interface or, failing that, its first IPv6 address.
+	Interface string
 }
 
 // A GracefulServer maintains a WaitGroup that counts how many in-flight
@@ -73,13 +239,86 @@ type GracefulServer struct {
 	shutdownFinished chan bool
 	wg               waitGroup
 	listener         *GracefulListener
+	name             string
+	label            atomic.Value // holds the identifying string once serving.
+	addr             atomic.Value // holds the listener address once serving.
 	stateHandler     StateHandler
+	controlSocket    string
+	agentCheckAddr   string
+
+	handler           *gracefulHandler
+	stats             drainStats
+	events            chan Event
+	eventsClosed      bool // guarded by eventsMutex.
+	eventsMutex       sync.Mutex
+	trace             shutdownTrace
+	poolConns         bool
+	onShutdown        []func()
+	started           bool // guarded by hookMutex; whether Serve has been called.
+	closedEarly       bool // guarded by hookMutex; whether shutdown was requested before Serve.
+	drainables        []Drainable
+	hookMutex         sync.Mutex
+	result            *DrainResult
+	healthChecks      []healthCheck
+	healthMutex       sync.RWMutex
+	drainDelay        time.Duration
+	deregister        func(context.Context) error
+	drainTimeout      time.Duration
+	drainDeadline     int64 // accessed atomically; UnixNano when the drain timeout expires, or zero.
+	streamingGrace    time.Duration
+	drainSilenceLimit time.Duration
+	progressInterval  time.Duration
+	trackConns        bool
+	conns             map[*gracefulConn]struct{} // only used with trackConns.
+	hijackPolicy      HijackPolicy
+	onConnError       func(*ConnError)
+	hijacked          map[*gracefulConn]struct{} // only used with HijackWait and HijackDrain.
+	hijackedClosed    chan struct{}              // signalled when a hijacked connection closes.
+	holds             map[*Hold]struct{}
+	diagnostics       bool
+	routines          map[*routineEntry]struct{} // only used with diagnostics.
+	unpaired          []*routineEntry            // StartRoutine calls, most recent last.
+	routinesMutex     sync.Mutex
+	connsMutex        sync.Mutex
+	drainRequestLimit int32
+	drainLock         DrainLock
+	maxConnAge        time.Duration
+	webhooks          []string
+	webhookMutex      sync.Mutex // held while the drain_started webhook is being delivered.
+	drainingHeader    string
+	drained           int32 // accessed atomically; requests completed since shutdown started.
+	verifyPeer        PeerVerifier
+	beforeAccept      func()
+	afterAccept       AcceptHook
+	deferAccept       bool
+	iface             string
+	fcgi              bool
+
+	tlsKeyLogWriter     io.Writer
+	unsafeTLSKeyLog     bool
+	tlsMinVersion       uint16
+	tlsCipherSuites     []uint16
+	tlsCurvePreferences []tls.CurveID
 
 	up chan net.Listener // Only used by test code.
 
 	signal os.Signal
+
+	phase        int32         // accessed atomically.
+	active       int32         // accessed atomically; mirrors the WaitGroup count.
+	lastActivity int64         // accessed atomically; UnixNano of the latest state change.
+	done         chan struct{} // closed when Serve has finished.
 }
 
+const (
+	phaseStarting int32 = iota
+	phaseServing
+	phaseDraining
+	phaseStopped
+)
+
+var phaseNames = []string{"starting", "serving", "draining", "stopped"}
+
 // NewServer creates a new GracefulServer.
 func NewServer() *GracefulServer {
 	return NewWithServer(new(http.Server))
@@ -93,6 +332,8 @@ func NewWithServer(s *http.Server) *GracefulServer {
 		shutdown:         make(chan bool),
 		shutdownFinished: make(chan bool, 1),
 		wg:               new(sync.WaitGroup),
+		done:             make(chan struct{}),
+		hijackedClosed:   make(chan struct{}, 1),
 	}
 }
 
@@ -108,32 +349,109 @@ func NewWithOptions(o Options) *GracefulServer {
 		}
 	}
 
-	return &GracefulServer{
-		listener:         listener,
-		Server:           o.Server,
-		stateHandler:     o.StateHandler,
-		shutdown:         make(chan bool),
-		shutdownFinished: make(chan bool, 1),
-		wg:               new(sync.WaitGroup),
-	}
+	s := NewWithServer(o.Server)
+	s.listener = listener
+	s.name = o.Name
+	s.stateHandler = o.StateHandler
+	s.controlSocket = o.ControlSocket
+	s.agentCheckAddr = o.AgentCheckAddr
+	s.drainDelay = o.DrainDelay
+	s.deregister = o.Deregister
+	s.drainTimeout = o.DrainTimeout
+	s.streamingGrace = o.StreamingGrace
+	s.drainSilenceLimit = o.DrainSilenceLimit
+	s.progressInterval = o.DrainProgressInterval
+	s.hijackPolicy = o.HijackPolicy
+	s.onConnError = o.OnConnError
+	s.diagnostics = o.RoutineDiagnostics
+	s.trackConns = o.TrackConnections || o.StreamingGrace > 0 || o.DrainSilenceLimit > 0
+	s.drainRequestLimit = int32(o.DrainRequestLimit)
+	s.drainLock = o.DrainLock
+	s.maxConnAge = o.MaxConnAge
+	s.webhooks = o.DrainWebhooks
+	s.drainingHeader = o.DrainingResponseHeader
+	s.trace.tracer = o.Tracer
+	s.poolConns = o.PoolConns
+	s.verifyPeer = o.VerifyPeer
+	s.beforeAccept = o.BeforeAccept
+	s.afterAccept = o.AfterAccept
+	s.deferAccept = o.DeferAccept
+	s.iface = o.Interface
+	s.tlsKeyLogWriter = o.TLSKeyLogWriter
+	s.unsafeTLSKeyLog = o.UnsafeTLSKeyLog
+	s.tlsMinVersion = o.TLSMinVersion
+	s.tlsCipherSuites = o.TLSCipherSuites
+	s.tlsCurvePreferences = o.TLSCurvePreferences
+	return s
 }
 
 // Close stops the server from accepting new requets and begins shutting down.
-// It returns true if it's the first time Close is called.
+// It returns true if it's the first time Close is called. With a DrainLock, the
+// server continues to report that it is serving until the lock is held.
 func (s *GracefulServer) Close() bool {
-	logger.Printf("Shutting down server on %s\n", s.Server.Addr)
-	return <-s.shutdown
+	logger.Printf("Shutting down server on %s\n", s.ident())
+	first := <-s.shutdown
+	if s.drainLock == nil {
+		atomic.CompareAndSwapInt32(&s.phase, phaseServing, phaseDraining)
+	}
+	return first
 }
 
 // BlockingClose is similar to Close, except that it blocks until the last
 // connection has been closed.
 func (s *GracefulServer) BlockingClose() bool {
-	logger.Printf("Shutting down server on %s (blocking)\n", s.Server.Addr)
+	logger.Printf("Shutting down server on %s (blocking)\n", s.ident())
 	result := s.Close()
 	<-s.shutdownFinished
 	return result
 }
 
+// Stop closes the listener and all open connections immediately, without
+// waiting for in-flight requests to complete. Hijacked connections are not
+// affected, unless the HijackPolicy is HijackWait or HijackDrain. If the server has not started
+// serving, any later call to Serve returns http.ErrServerClosed.
+func (s *GracefulServer) Stop() error {
+	logger.Printf("Stopping server on %s\n", s.ident())
+	if s.closeBeforeServe() {
+		return nil
+	}
+	go s.Close()
+	err := s.Server.Close()
+	s.closeHijacked()
+	return err
+}
+
+// setLabel records the listener address and the string that identifies the
+// server in logs, events and webhooks.
+func (s *GracefulServer) setLabel(addr net.Addr) {
+	s.addr.Store(addr.String())
+	if s.name == "" {
+		s.label.Store(addr.String())
+	} else {
+		s.label.Store(s.name + " (" + addr.String() + ")")
+	}
+}
+
+// ListenAddr returns the address the server is listening on, or an empty
+// string before it has started. When Addr is a list of fallbacks, this is the
+// one that was chosen; Addr itself keeps the whole list.
+func (s *GracefulServer) ListenAddr() string {
+	addr, _ := s.addr.Load().(string)
+	return addr
+}
+
+// ident returns the string that identifies the server in logs, events and
+// webhooks.
+func (s *GracefulServer) ident() string {
+	if label, ok := s.label.Load().(string); ok {
+		return label
+	}
+	if s.name != "" {
+		return s.name
+	}
+	return s.Server.Addr
+}
+
 func isUnixNetwork(addr string) bool {
 	return strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, ".")
 }
@@ -154,29 +472,95 @@ func listenToUnix(bind string) (listener net.Listener, err error) {
 	return
 }
 
-func listen(bind string) (listener net.Listener, err error) {
+func (s *GracefulServer) listen(bind string) (listener net.Listener, err error) {
 	if isUnixNetwork(bind) {
-		logger.Printf("Listening on unix socket %s\n", bind)
+		logger.Printf("Listening on unix socket %s for %s\n", bind, s.ident())
 		return listenToUnix(bind)
+	} else if isVsockNetwork(bind) {
+		addr, err := parseVsockAddr(bind)
+		if err != nil {
+			return nil, err
+		}
+		logger.Printf("Listening on vsock socket %s for %s\n", bind, s.ident())
+		return listenVsock(addr)
 	} else if strings.Contains(bind, ":") {
-		logger.Printf("Listening on tcp socket %s\n", bind)
+		logger.Printf("Listening on tcp socket %s for %s\n", bind, s.ident())
 		return net.Listen("tcp", bind)
 	} else {
 		return nil, fmt.Errorf("error while parsing bind arg %v", bind)
 	}
 }
 
+// bindAddrs determines the addresses to try listening on, in order. These are
+// given by Addr or otherwise the default, with the host replaced by the address
+// of the configured network interface, if any. For TCP, Addr may be a
+// comma-separated list of fallbacks, such as ":8080,:8081,:0".
+func (s *GracefulServer) bindAddrs(defaultAddr string) ([]string, error) {
+	addr := s.Addr
+	if addr == "" {
+		addr = defaultAddr
+	}
+	if isUnixNetwork(addr) || isVsockNetwork(addr) {
+		return []string{addr}, nil
+	}
+
+	addrs := strings.Split(addr, ",")
+	for i, a := range addrs {
+		addrs[i] = strings.TrimSpace(a)
+		if s.iface != "" {
+			bind, err := interfaceAddr(s.iface, addrs[i])
+			if err != nil {
+				return nil, err
+			}
+			addrs[i] = bind
+		}
+	}
+	return addrs, nil
+}
+
+// listenAny listens on the first of the addresses that is available. If none
+// is, it returns the error for the last one.
+func (s *GracefulServer) listenAny(addrs []string, listen func(string) (net.Listener, error)) (l net.Listener, err error) {
+	for i, addr := range addrs {
+		if l, err = listen(addr); err == nil {
+			return l, nil
+		}
+		if i < len(addrs)-1 {
+			logger.Printf("Cannot listen on %s for %s, trying %s: %v\n", addr, s.ident(), addrs[i+1], err)
+		}
+	}
+	return nil, err
+}
+
+var errDeferAcceptUnsupported = errors.New("deferred accept is not supported on this platform")
+
+// tuneListener applies the server's socket options to a newly created listener.
+func (s *GracefulServer) tuneListener(l net.Listener) error {
+	if tl, ok := l.(*net.TCPListener); ok && s.deferAccept {
+		if err := setDeferAccept(tl); err != errDeferAcceptUnsupported {
+			return err
+		}
+		logger.Printf("Deferred accept is not supported on this platform; ignored for %s\n", s.ident())
+	}
+	return nil
+}
+
 // ListenAndServe provides a graceful equivalent of net/http.Serve.ListenAndServe.
+// Addr may be a comma-separated list of TCP addresses to try in turn.
 func (s *GracefulServer) ListenAndServe() error {
 	if s.listener == nil {
-		addr := s.Addr
-		if addr == "" {
-			addr = ":http"
+		addrs, err := s.bindAddrs(":http")
+		if err != nil {
+			return err
 		}
-		oldListener, err := listen(addr)
+		oldListener, err := s.listenAny(addrs, s.listen)
 		if err != nil {
 			return err
 		}
+		if err = s.tuneListener(oldListener); err != nil {
+			oldListener.Close()
+			return err
+		}
 		s.listener = NewListener(oldListener)
 	}
 	return s.Serve(s.listener)
@@ -184,50 +568,79 @@ func (s *GracefulServer) ListenAndServe() error {
 
 // ListenAndServeTLS provides a graceful equivalent of net/http.Serve.ListenAndServeTLS.
 func (s *GracefulServer) ListenAndServeTLS(certFile, keyFile string) error {
-	// direct lift from net/http/server.go
-	addr := s.Addr
-	if addr == "" {
-		addr = ":https"
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
 	}
+
+	return s.listenAndServeTLSWithCertificate(cert)
+}
+
+func (s *GracefulServer) listenAndServeTLSWithCertificate(cert tls.Certificate) error {
+	return s.ListenAndServeTLSWithConfig(s.certificateConfig(cert))
+}
+
+// certificateConfig returns a copy of the server's TLSConfig using the
+// given certificate.
+func (s *GracefulServer) certificateConfig(cert tls.Certificate) *tls.Config {
+	// direct lift from net/http/server.go
 	config := &tls.Config{}
 	if s.TLSConfig != nil {
-		*config = *s.TLSConfig
+		config = s.TLSConfig.Clone()
 	}
 	if config.NextProtos == nil {
 		config.NextProtos = []string{"http/1.1"}
 	}
-
-	var err error
-	config.Certificates = make([]tls.Certificate, 1)
-	config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return err
-	}
-
-	return s.ListenAndServeTLSWithConfig(config)
+	config.Certificates = []tls.Certificate{cert}
+	return config
 }
 
 // ListenAndServeTLSWithConfig provides a graceful equivalent of net/http.Serve.ListenAndServeTLS
-// using a bespoke TLS config.
+// using a bespoke TLS config. Addr may be a comma-separated list of addresses to try in turn.
 func (s *GracefulServer) ListenAndServeTLSWithConfig(config *tls.Config) error {
-	addr := s.Addr
-	if addr == "" {
-		addr = ":https"
-	}
-
 	if s.listener == nil {
-		logger.Printf("Listening on tcp socket %s\n", addr)
-		ln, err := net.Listen("tcp", addr)
+		addrs, err := s.bindAddrs(":https")
 		if err != nil {
 			return err
 		}
 
+		config, err := s.tlsConfig(config)
+		if err != nil {
+			return err
+		}
+
+		ln, err := s.listenAny(addrs, func(addr string) (net.Listener, error) {
+			logger.Printf("Listening on tcp socket %s for %s\n", addr, s.ident())
+			return net.Listen("tcp", addr)
+		})
+		if err != nil {
+			return err
+		}
+		if err = s.tuneListener(ln); err != nil {
+			ln.Close()
+			return err
+		}
+
 		tlsListener := NewTLSListener(TCPKeepAliveListener{ln.(*net.TCPListener)}, config)
 		s.listener = NewListener(tlsListener)
 	}
 	return s.Serve(s.listener)
 }
 
+// ServeTLS provides a graceful equivalent of net/http.Server.ServeTLS.
+func (s *GracefulServer) ServeTLS(listener net.Listener, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	config, err := s.tlsConfig(s.certificateConfig(cert))
+	if err != nil {
+		return err
+	}
+	return s.Serve(NewTLSListener(listener, config))
+}
+
 func (gs *GracefulServer) GetFile() (*os.File, error) {
 	return gs.listener.GetFile()
 }
@@ -252,6 +665,21 @@ func (gs *GracefulServer) HijackListener(s *http.Server, config *tls.Config) (*G
 // If listener is not an instance of *GracefulListener it will be wrapped
 // to become one.
 func (s *GracefulServer) Serve(listener net.Listener) error {
+	if !s.beginServing() {
+		listener.Close()
+		return http.ErrServerClosed
+	}
+	if s.controlSocket != "" {
+		if err := s.ServeControl(s.controlSocket); err != nil {
+			return err
+		}
+	}
+	if s.agentCheckAddr != "" {
+		if err := s.ServeAgentCheck(s.agentCheckAddr); err != nil {
+			return err
+		}
+	}
+
 	// Accept a net.Listener to preserve the interface compatibility with the
 	// standard http.Server. If it is not a GracefulListener then wrap it into
 	// one.
@@ -261,22 +689,36 @@ func (s *GracefulServer) Serve(listener net.Listener) error {
 		listener = gracefulListener
 	}
 	s.listener = gracefulListener
+	s.setLabel(listener.Addr())
+	gracefulListener.beforeAccept = s.beforeAccept
+	gracefulListener.afterAccept = s.afterAccept
+	gracefulListener.ident = s.ident
+	if s.verifyPeer != nil {
+		gracefulListener.verifyPeer = s.verifyPeer
+		s.Server.ConnContext = peerCredContext(s.Server.ConnContext)
+	}
+	s.Server.BaseContext = serverContext(s, s.Server.BaseContext)
+	if s.onConnError != nil {
+		s.Server.ErrorLog = log.New(&errorLogWriter{s.onConnError, s.Server.ErrorLog}, "", 0)
+	}
+	atomic.StoreInt32(&s.phase, phaseServing)
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
 
 	// Wrap the server HTTP handler into graceful one, that will close kept
 	// alive connections if a new request is received after shutdown.
-	gracefulHandler := newGracefulHandler(s.Server.Handler)
+	s.hookMutex.Lock()
+	gracefulHandler := newGracefulHandler(s.Server.Handler, &s.stats)
+	gracefulHandler.maxConnAge = s.maxConnAge
+	gracefulHandler.phase = &s.phase
+	gracefulHandler.drainingHeader = s.drainingHeader
 	s.Server.Handler = gracefulHandler
+	s.handler = gracefulHandler
+	s.hookMutex.Unlock()
 
 	// Start a goroutine that waits for a shutdown signal and will stop the
 	// listener when it receives the signal. That in turn will result in
 	// unblocking of the http.Serve call.
-	go func() {
-		s.shutdown <- true
-		close(s.shutdown)
-		gracefulHandler.Close()
-		s.Server.SetKeepAlivesEnabled(false)
-		gracefulListener.Close()
-	}()
+	go s.drain(gracefulHandler, gracefulListener)
 
 	originalConnState := s.Server.ConnState
 
@@ -287,32 +729,62 @@ func (s *GracefulServer) Serve(listener net.Listener) error {
 		gracefulConn := retrieveGracefulConn(conn)
 		oldState := gracefulConn.lastHTTPState
 		gracefulConn.lastHTTPState = newState
+		s.touch()
 
 		switch newState {
 
 		case http.StateNew:
 			// New connection -> StateNew
 			gracefulConn.protected = true
-			s.StartRoutine()
+			if s.maxConnAge > 0 {
+				gracefulConn.created = time.Now()
+			}
+			if s.trackConns {
+				s.trackConn(gracefulConn)
+			}
+			gracefulConn.onError = s.onConnError
+			gracefulConn.routine = s.startRoutine(s.connSite(gracefulConn), "")
+			if s.events != nil {
+				s.emit(ConnOpened, conn.RemoteAddr(), nil)
+			}
 
 		case http.StateActive:
 			// (StateNew, StateIdle) -> StateActive
 			if gracefulHandler.IsClosed() {
+				gracefulConn.rejected = true
 				gracefulConn.Close()
 				break
 			}
 
 			if !gracefulConn.protected {
 				gracefulConn.protected = true
-				s.StartRoutine()
+				gracefulConn.routine = s.startRoutine(s.connSite(gracefulConn), "")
 			}
 
 		default:
 			// (StateNew, StateActive) -> (StateIdle, StateClosed, StateHiJacked)
-			if gracefulConn.protected {
-				s.FinishRoutine()
+			if newState == http.StateHijacked && gracefulConn.protected && s.hijackPolicy == HijackWait {
+				s.trackHijacked(gracefulConn)
+				gracefulConn.protected = false
+			} else if gracefulConn.protected {
+				if newState == http.StateHijacked && s.hijackPolicy == HijackDrain {
+					s.trackHijacked(gracefulConn)
+				}
+				s.finishRoutine(gracefulConn.routine)
+				gracefulConn.routine = nil
 				gracefulConn.protected = false
 			}
+
+			if newState != http.StateIdle && s.events != nil {
+				s.emit(ConnClosed, conn.RemoteAddr(), nil)
+			}
+
+			if oldState == http.StateActive && !gracefulConn.rejected &&
+				s.drainRequestLimit > 0 && gracefulHandler.IsClosed() {
+				if atomic.AddInt32(&s.drained, 1) == s.drainRequestLimit {
+					s.forceClose("drain request limit reached")
+				}
+			}
 		}
 
 		if s.stateHandler != nil {
@@ -322,8 +794,21 @@ func (s *GracefulServer) Serve(listener net.Listener) error {
 		if originalConnState != nil {
 			originalConnState(conn, newState)
 		}
+
+		if gracefulConn.tracked {
+			atomic.StoreInt32(&gracefulConn.state, int32(newState))
+			if newState == http.StateClosed || newState == http.StateHijacked {
+				s.untrackConn(gracefulConn)
+			}
+		}
+
+		if newState == http.StateClosed && s.poolConns {
+			gracefulConn.release()
+		}
 	}
 
+	s.emit(ListenerUp, listener.Addr(), nil)
+
 	// A hook to allow the server to notify others when it is ready to receive
 	// requests; only used by tests.
 	if s.up != nil {
@@ -331,55 +816,223 @@ func (s *GracefulServer) Serve(listener net.Listener) error {
 	}
 
 	var err error
-	if isUnixNetwork(s.Server.Addr) {
+	if s.fcgi {
+		err = s.serveFCGI(listener, s.trackRoutine(s.Server.Handler))
+	} else if isUnixNetwork(s.Server.Addr) {
 		os.Chmod(s.Server.Addr, os.ModePerm)
-		err = fcgi.Serve(listener, s.Server.Handler)
+		err = s.serveFCGI(listener, s.trackRoutine(s.Server.Handler))
 	} else {
 		err = s.Server.Serve(listener)
 	}
 
 	// An error returned on shutdown is not worth reporting.
-	if _, ok = err.(listenerAlreadyClosed); ok {
+	if _, ok = err.(listenerAlreadyClosed); ok || err == http.ErrServerClosed {
 		err = nil
 	}
 
 	// Wait for pending requests to complete regardless the Serve result.
+	s.finish()
+	return err
+}
+
+// finish waits for pending requests to complete, then records the drain
+// result and notifies those waiting for the server to stop.
+func (s *GracefulServer) finish() {
 	s.wg.Wait()
+	s.stats.mark(func(r *DrainResult) *time.Time { return &r.RequestsDone })
+	s.drainSubsystems()
+	s.trace.end()
+	s.result = s.stats.finish()
+	logger.Printf("Server on %s drained %d in-flight requests; 50%% within %v, 90%% within %v, all within %v\n",
+		s.ident(), s.result.InFlight, s.result.Percentile(0.5), s.result.Percentile(0.9), s.result.Percentile(1))
+	if !s.result.ShutdownStarted.IsZero() {
+		logger.Printf("Server on %s shut down in %v: listener closed after %v, requests done after %v\n",
+			s.ident(), s.result.Duration(), s.result.ListenerClosed.Sub(s.result.ShutdownStarted),
+			s.result.RequestsDone.Sub(s.result.ShutdownStarted))
+		if !s.result.Deregistered.IsZero() {
+			logger.Printf("Server on %s was deregistered after %v\n",
+				s.ident(), s.result.Deregistered.Sub(s.result.ShutdownStarted))
+		}
+	}
+	s.notifyDrainComplete(s.result)
+	atomic.StoreInt32(&s.phase, phaseStopped)
+	s.emit(DrainComplete, nil, s.result)
+	s.closeEvents()
+	close(s.done)
 	s.shutdownFinished <- true
-	return err
+}
+
+// drain waits for a shutdown signal, then stops the server accepting new
+// requests and enforces the drain timeout, if any.
+func (s *GracefulServer) drain(gracefulHandler *gracefulHandler, gracefulListener *GracefulListener) {
+	s.shutdown <- true
+	close(s.shutdown)
+	s.stats.begin()
+	s.trace.begin()
+	s.emit(ShutdownRequested, nil, nil)
+	s.notifyDrainStarted()
+	s.runOnShutdown()
+	locked := s.lockDrain()
+	// While waiting for the lock, the server stays in rotation so that the
+	// servers sharing it do not all drop out together.
+	atomic.CompareAndSwapInt32(&s.phase, phaseServing, phaseDraining)
+
+	if s.deregister != nil {
+		s.trace.step("manners.deregister")
+		logger.Printf("Deregistering %s from its load balancer\n", s.ident())
+		if err := s.deregister(context.Background()); err != nil {
+			logger.Printf("Failed to deregister %s: %v\n", s.ident(), err)
+		}
+		s.stats.mark(func(r *DrainResult) *time.Time { return &r.Deregistered })
+	}
+
+	// Keep serving while reporting not-ready, so that load balancers have
+	// time to stop sending new requests.
+	if s.drainDelay > 0 {
+		s.trace.step("manners.drain_delay")
+		logger.Printf("Waiting %v before closing the listener on %s\n", s.drainDelay, s.ident())
+		time.Sleep(s.drainDelay)
+	}
+
+	// The drain timeout runs from here, for requests and subsystems alike.
+	var deadline time.Time
+	if s.drainTimeout > 0 {
+		deadline = time.Now().Add(s.drainTimeout)
+		atomic.StoreInt64(&s.drainDeadline, deadline.UnixNano())
+	}
+
+	s.trace.step("manners.listener_close")
+	gracefulHandler.Close()
+	s.Server.SetKeepAlivesEnabled(false)
+	gracefulListener.Close()
+	s.stats.mark(func(r *DrainResult) *time.Time { return &r.ListenerClosed })
+	s.trace.step("manners.request_wait")
+	go s.reportProgress()
+
+	if s.drainTimeout > 0 {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case <-s.done:
+		case <-timer.C:
+			if s.streamingGrace == 0 || !s.awaitStreams() {
+				s.forceClose("drain timeout expired")
+			}
+		}
+	}
+
+	if locked {
+		<-s.done
+		s.unlockDrain()
+	}
+}
+
+// DrainResult describes how the server shut down. It returns nil until Serve has
+// finished.
+func (s *GracefulServer) DrainResult() *DrainResult {
+	select {
+	case <-s.done:
+		return s.result
+	default:
+		return nil
+	}
+}
+
+// forceClose ends the drain early by closing all remaining connections.
+func (s *GracefulServer) forceClose(reason string) {
+	logger.Printf("Closing remaining connections on %s: %s\n", s.ident(), reason)
+	s.logHolds()
+	s.Server.Close()
+	s.closeHijacked()
+}
+
+// activityResolution limits how often lastActivity is written, so that busy
+// servers do not contend on it from every core.
+const activityResolution = int64(time.Millisecond)
+
+// touch records the time of the latest connection state change.
+func (s *GracefulServer) touch() {
+	now := time.Now().UnixNano()
+	if now-atomic.LoadInt64(&s.lastActivity) >= activityResolution {
+		atomic.StoreInt64(&s.lastActivity, now)
+	}
+}
+
+// SetHandler atomically replaces the server's handler. Requests already in
+// progress finish on the old handler; subsequent requests use the new one. This
+// allows configuration or routes to be reloaded without interrupting the listener.
+// If h is nil, http.DefaultServeMux is used.
+func (s *GracefulServer) SetHandler(h http.Handler) {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	if s.handler != nil {
+		s.handler.setWrapped(h)
+	} else {
+		s.Server.Handler = h
+	}
 }
 
 // StartRoutine increments the server's WaitGroup. Use this if a web request
 // starts more goroutines and these goroutines are not guaranteed to finish
-// before the request.
+// before the request. Hold does the same but names the routine, which makes
+// a leaked routine easier to find.
 func (s *GracefulServer) StartRoutine() {
-	s.wg.Add(1)
+	if !s.diagnostics {
+		s.startRoutine("", "")
+		return
+	}
+	e := s.startRoutine(callerSite(1), callerStack())
+	s.routinesMutex.Lock()
+	s.unpaired = append(s.unpaired, e)
+	s.routinesMutex.Unlock()
 }
 
 // FinishRoutine decrements the server's WaitGroup. Use this to complement
 // StartRoutine().
 func (s *GracefulServer) FinishRoutine() {
-	s.wg.Done()
+	if !s.diagnostics {
+		s.finishRoutine(nil)
+		return
+	}
+	s.routinesMutex.Lock()
+	n := len(s.unpaired)
+	if n == 0 {
+		s.routinesMutex.Unlock()
+		panic("manners: FinishRoutine called more often than StartRoutine, by " + callerSite(1))
+	}
+	e := s.unpaired[n-1]
+	s.unpaired = s.unpaired[:n-1]
+	s.routinesMutex.Unlock()
+	s.finishRoutine(e)
 }
 
 // CloseOnInterrupt creates a go-routine that will call the Close() function when certain OS
 // signals are received. If no signals are specified,
 // the following are used: SIGINT, SIGTERM, SIGKILL, SIGQUIT, SIGHUP, SIGUSR1.
+// If another of the signals is received while the server is draining, the Stop() function
+// is called to close all connections immediately.
 // This function must be called before ListenAndServe, ListenAndServeTLS, or Serve.
 func (s *GracefulServer) CloseOnInterrupt(signals ...os.Signal) *GracefulServer {
 	if s == nil {
 		panic("Program error: the server must exist before this method is called.")
 	}
+	sigchan := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		signal.Notify(sigchan, signals...)
+	} else {
+		signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL,
+			syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGUSR1)
+	}
 	go func(rx *GracefulServer) {
-		sigchan := make(chan os.Signal, 1)
-		if len(signals) > 0 {
-			signal.Notify(sigchan, signals...)
-		} else {
-			signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL,
-				syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGUSR1)
-		}
+		defer signal.Stop(sigchan)
 		rx.signal = <-sigchan
 		rx.Close()
+		select {
+		case sig := <-sigchan:
+			logger.Printf("Received %v while draining %s\n", sig, rx.ident())
+			rx.Stop()
+		case <-rx.done:
+		}
 	}(s)
 	return s
 }
@@ -398,19 +1051,54 @@ func (s *GracefulServer) SignalReceived() os.Signal {
 // gracefulHandler is used by GracefulServer to prevent calling ServeHTTP on
 // to be closed kept-alive connections during the server shutdown.
 type gracefulHandler struct {
-	closed  int32 // accessed atomically.
-	wrapped http.Handler
+	closed     int32        // accessed atomically.
+	wrapped    atomic.Value // holds a handlerRef.
+	stats      *drainStats
+	maxConnAge time.Duration
+
+	// phase, if not nil, points to the server's phase so that responses can
+	// be marked once shutdown has started.
+	phase          *int32
+	drainingHeader string
+}
+
+func newGracefulHandler(wrapped http.Handler, stats *drainStats) *gracefulHandler {
+	gh := &gracefulHandler{stats: stats}
+	gh.setWrapped(wrapped)
+	return gh
 }
 
-func newGracefulHandler(wrapped http.Handler) *gracefulHandler {
-	return &gracefulHandler{
-		wrapped: wrapped,
+// handlerRef allows handlers of differing types to be held in an atomic.Value.
+type handlerRef struct {
+	http.Handler
+}
+
+func (gh *gracefulHandler) setWrapped(wrapped http.Handler) {
+	if wrapped == nil {
+		wrapped = http.DefaultServeMux
 	}
+	gh.wrapped.Store(handlerRef{wrapped})
 }
 
 func (gh *gracefulHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if atomic.LoadInt32(&gh.closed) == 0 {
-		gh.wrapped.ServeHTTP(w, r)
+		start := gh.stats.requestStarted()
+		defer gh.stats.requestFinished(start)
+		if gh.maxConnAge > 0 && gh.connExpired(r) {
+			w.Header().Set("Connection", "close")
+		}
+		if gh.phase != nil && atomic.LoadInt32(gh.phase) >= phaseDraining {
+			// Keep-alives remain enabled during the drain delay, so ask
+			// clients not to reuse the connection. HTTP/2 forbids
+			// connection-specific headers, so only HTTP/1 is hinted.
+			if r.ProtoMajor == 1 {
+				w.Header().Set("Keep-Alive", "timeout=0, max=1")
+			}
+			if gh.drainingHeader != "" {
+				w.Header().Set(gh.drainingHeader, "1")
+			}
+		}
+		gh.wrapped.Load().(handlerRef).ServeHTTP(w, r)
 		return
 	}
 	r.Body.Close()
@@ -419,6 +1107,15 @@ func (gh *gracefulHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// actually execute the handler logic.
 }
 
+// connExpired reports whether the request's connection is older than maxConnAge.
+func (gh *gracefulHandler) connExpired(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(*gracefulAddr)
+	if !ok || addr.gconn.created.IsZero() {
+		return false
+	}
+	return time.Since(addr.gconn.created) >= gh.maxConnAge
+}
+
 func (gh *gracefulHandler) Close() {
 	atomic.StoreInt32(&gh.closed, 1)
 }
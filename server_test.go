@@ -2,9 +2,12 @@ package manners
 
 import (
 	helpers "github.com/rickb777/manners/test_helpers"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"runtime"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -13,6 +16,7 @@ type httpInterface interface {
 	ListenAndServe() error
 	ListenAndServeTLS(certFile, keyFile string) error
 	Serve(listener net.Listener) error
+	ServeTLS(listener net.Listener, certFile, keyFile string) error
 }
 
 // Test that the method signatures of the methods we override from net/http/Server match those of the original.
@@ -342,6 +346,7 @@ func TestWrapConnectionUnix(t *testing.T) {
 	//if !called {
 	//	t.Error("Handler was not called")
 	//}
+	_ = called
 }
 
 // Hijack listener
@@ -421,3 +426,171 @@ func TestHijackListener(t *testing.T) {
 		t.Error("Unexpected error during shutdown", err)
 	}
 }
+
+// Test that ServeTLS wraps the supplied listener for TLS and serves gracefully.
+func TestServeTLS(t *testing.T) {
+	keyFile, err1 := helpers.NewTempFile(helpers.Key)
+	certFile, err2 := helpers.NewTempFile(helpers.Cert)
+	defer keyFile.Unlink()
+	defer certFile.Unlink()
+	if err1 != nil || err2 != nil {
+		t.Fatal("Failed to create temporary files", err1, err2)
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal("Failed to create listener", err)
+	}
+
+	server := NewServer()
+	runner := func() error {
+		return server.ServeTLS(l, certFile.Name(), keyFile.Name())
+	}
+	listener, exitchan := startGenericServer(t, server, nil, runner)
+
+	client := newClient(listener.Addr(), true)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	if rr := <-client.response; rr.err != nil || len(rr.body) == 0 {
+		t.Fatalf("Unexpected response %v, %v", rr.body, rr.err)
+	}
+	close(client.sendrequest)
+	<-client.closed
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Test that keep-alive connections older than MaxConnAge are closed after
+// their next response.
+func TestMaxConnAge(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), MaxConnAge: 30 * time.Millisecond})
+	server.Handler = nullHandler
+	client, errc := server.ServeInMemory()
+
+	get := func() *http.Response {
+		resp, err := client.Get("http://manners/")
+		if err != nil {
+			t.Fatal("Get failed", err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get(); resp.Close {
+		t.Error("Expected a new connection to be kept alive")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if resp := get(); !resp.Close {
+		t.Error("Expected an old connection to be closed")
+	}
+
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Test that a second signal received while draining stops the server.
+func TestCloseOnInterruptTwice(t *testing.T) {
+	started := make(chan bool)
+	server := NewWithServer(&http.Server{Handler: blockingHandler(started)})
+	listener, exitchan := startServer(t, server, nil)
+	server.CloseOnInterrupt(syscall.SIGUSR2)
+	go http.Get("http://" + listener.Addr().String())
+	<-started
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+	for !server.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not stop on the second signal")
+	}
+}
+
+// Test that a replacement handler is used for subsequent requests while an
+// in-flight request finishes on the old handler.
+func TestSetHandler(t *testing.T) {
+	text := func(s string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s))
+		})
+	}
+	server := NewServer()
+	server.SetHandler(text("first"))
+
+	started := make(chan bool)
+	release := make(chan bool)
+	mux := http.NewServeMux()
+	mux.Handle("/", text("second"))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+		w.Write([]byte("slow"))
+	})
+
+	client, errc := server.ServeInMemory()
+	get := func(path string) string {
+		resp, err := client.Get("http://manners" + path)
+		if err != nil {
+			t.Fatal("Get failed", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	if body := get("/"); body != "first" {
+		t.Errorf("Expected the first handler, got %q", body)
+	}
+	server.SetHandler(mux)
+	slow := make(chan string)
+	go func() { slow <- get("/slow") }()
+	<-started
+	server.SetHandler(text("third"))
+	close(release)
+	if body := <-slow; body != "slow" {
+		t.Errorf("Expected the in-flight request to finish on its handler, got %q", body)
+	}
+	if body := get("/"); body != "third" {
+		t.Errorf("Expected the third handler, got %q", body)
+	}
+
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Tests that stopping a server that never served does not leave goroutines
+// waiting for it, and that it then refuses to serve.
+func TestStopBeforeServe(t *testing.T) {
+	server := NewWithServer(&http.Server{Addr: "localhost:0", Handler: nullHandler})
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if err := server.Stop(); err != nil {
+			t.Error("Unexpected error from Stop", err)
+		}
+	}
+	if after := runtime.NumGoroutine(); after >= before+10 {
+		t.Errorf("Expected Stop not to leak goroutines; %d before, %d after", before, after)
+	}
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
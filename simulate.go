@@ -0,0 +1,38 @@
+package manners
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// A DrainSimulation describes what would hold up a drain if one started now.
+type DrainSimulation struct {
+	// Routines is the number of connections with requests in progress, plus
+	// any routines added by StartRoutine or Hold; a drain waits for all of them.
+	Routines int
+	// InFlight is the number of requests being handled.
+	InFlight int
+	// Blocking lists the connections that a drain would wait for, oldest first.
+	// Idle connections are not included because a drain closes them at once.
+	// It is nil unless connections are tracked; see Connections.
+	Blocking []ConnInfo
+	// Holds lists the outstanding holds, oldest first; see Hold.
+	Holds []HoldInfo
+}
+
+// SimulateDrain reports, without closing anything, how many connections and
+// requests would currently hold up a drain, and which ones. Operators can use
+// this to assess the impact of restarting the server before doing so.
+func (s *GracefulServer) SimulateDrain() *DrainSimulation {
+	sim := &DrainSimulation{
+		Routines: int(atomic.LoadInt32(&s.active)),
+		InFlight: int(atomic.LoadInt32(&s.stats.inFlight)),
+		Holds:    s.Holds(),
+	}
+	for _, c := range s.Connections() {
+		if c.State == http.StateNew || c.State == http.StateActive {
+			sim.Blocking = append(sim.Blocking, c)
+		}
+	}
+	return sim
+}
@@ -0,0 +1,29 @@
+package manners
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSimulateDrain(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), TrackConnections: true})
+	started := make(chan bool)
+	server.Handler = blockingHandler(started)
+	client, errc := server.ServeInMemory()
+
+	go client.Get("http://manners/")
+	<-started
+
+	sim := server.SimulateDrain()
+	if sim.Routines != 1 || sim.InFlight != 1 || len(sim.Blocking) != 1 || sim.Blocking[0].State != http.StateActive {
+		t.Errorf("Unexpected simulation %+v", sim)
+	}
+	if server.isDraining() {
+		t.Error("Expected the simulation not to start a drain")
+	}
+
+	server.Stop()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
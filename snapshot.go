@@ -0,0 +1,148 @@
+package manners
+
+import (
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// A Snapshot is the state of a GracefulServer at a moment in time, in a form
+// suitable for crash dumps, support bundles and incident tooling. In its JSON
+// encoding, names are in snake_case and durations are in milliseconds.
+type Snapshot struct {
+	Server      string          `json:"server"`
+	Address     string          `json:"address"`
+	PID         int             `json:"pid"`
+	Time        time.Time       `json:"time"`
+	Phase       string          `json:"phase"`
+	Routines    int             `json:"routines"`
+	InFlight    int             `json:"in_flight"`
+	Drained     int             `json:"drained"`
+	Connections []SnapshotConn  `json:"connections,omitempty"`
+	Holds       []SnapshotHold  `json:"holds,omitempty"`
+	Config      SnapshotConfig  `json:"config"`
+	Result      *SnapshotResult `json:"result,omitempty"`
+}
+
+// A SnapshotConn describes one of the connections in a Snapshot.
+type SnapshotConn struct {
+	RemoteAddr string    `json:"remote_addr"`
+	State      string    `json:"state"`
+	Opened     time.Time `json:"opened"`
+	LastRead   time.Time `json:"last_read"`
+	LastWrite  time.Time `json:"last_write"`
+}
+
+// A SnapshotHold describes one of the outstanding holds in a Snapshot.
+type SnapshotHold struct {
+	Name  string    `json:"name"`
+	Since time.Time `json:"since"`
+}
+
+// SnapshotConfig holds the drain settings in a Snapshot. Durations are in
+// milliseconds.
+type SnapshotConfig struct {
+	DrainDelayMs            float64 `json:"drain_delay_ms"`
+	DrainTimeoutMs          float64 `json:"drain_timeout_ms"`
+	StreamingGraceMs        float64 `json:"streaming_grace_ms"`
+	DrainSilenceLimitMs     float64 `json:"drain_silence_limit_ms"`
+	DrainProgressIntervalMs float64 `json:"drain_progress_interval_ms"`
+	DrainRequestLimit       int     `json:"drain_request_limit"`
+	MaxConnAgeMs            float64 `json:"max_conn_age_ms"`
+	HijackPolicy            string  `json:"hijack_policy"`
+	TrackConnections        bool    `json:"track_connections"`
+}
+
+// A SnapshotResult is the DrainResult in a Snapshot, once the server has
+// finished. Durations are in milliseconds.
+type SnapshotResult struct {
+	ShutdownStarted     time.Time `json:"shutdown_started"`
+	Deregistered        time.Time `json:"deregistered"`
+	ListenerClosed      time.Time `json:"listener_closed"`
+	RequestsDone        time.Time `json:"requests_done"`
+	Finished            time.Time `json:"finished"`
+	DurationMs          float64   `json:"duration_ms"`
+	InFlight            int       `json:"in_flight"`
+	InFlightDurationsMs []float64 `json:"in_flight_durations_ms"`
+}
+
+var hijackPolicyNames = [...]string{
+	HijackIgnore: "ignore",
+	HijackWait:   "wait",
+	HijackDrain:  "drain",
+}
+
+// milliseconds converts d for a Snapshot.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newSnapshotResult(r *DrainResult) *SnapshotResult {
+	if r == nil {
+		return nil
+	}
+	result := &SnapshotResult{
+		ShutdownStarted:     r.ShutdownStarted,
+		Deregistered:        r.Deregistered,
+		ListenerClosed:      r.ListenerClosed,
+		RequestsDone:        r.RequestsDone,
+		Finished:            r.Finished,
+		DurationMs:          milliseconds(r.Duration()),
+		InFlight:            r.InFlight,
+		InFlightDurationsMs: make([]float64, len(r.InFlightDurations)),
+	}
+	for i, d := range r.InFlightDurations {
+		result.InFlightDurationsMs[i] = milliseconds(d)
+	}
+	return result
+}
+
+// Snapshot returns the current state of the server. Connections are only
+// included if they are tracked; see Connections.
+func (s *GracefulServer) Snapshot() *Snapshot {
+	snap := &Snapshot{
+		Server:   s.ident(),
+		PID:      os.Getpid(),
+		Time:     time.Now(),
+		Phase:    phaseNames[atomic.LoadInt32(&s.phase)],
+		Routines: int(atomic.LoadInt32(&s.active)),
+		InFlight: int(atomic.LoadInt32(&s.stats.inFlight)),
+		Drained:  int(atomic.LoadInt32(&s.drained)),
+		Config: SnapshotConfig{
+			DrainDelayMs:            milliseconds(s.drainDelay),
+			DrainTimeoutMs:          milliseconds(s.drainTimeout),
+			StreamingGraceMs:        milliseconds(s.streamingGrace),
+			DrainSilenceLimitMs:     milliseconds(s.drainSilenceLimit),
+			DrainProgressIntervalMs: milliseconds(s.progressInterval),
+			DrainRequestLimit:       int(s.drainRequestLimit),
+			MaxConnAgeMs:            milliseconds(s.maxConnAge),
+			HijackPolicy:            hijackPolicyNames[s.hijackPolicy],
+			TrackConnections:        s.trackConns,
+		},
+		Result: newSnapshotResult(s.DrainResult()),
+	}
+	for _, h := range s.Holds() {
+		snap.Holds = append(snap.Holds, SnapshotHold{Name: h.Name, Since: h.Since})
+	}
+	if addr, ok := s.addr.Load().(string); ok {
+		snap.Address = addr
+	} else {
+		snap.Address = s.Server.Addr
+	}
+	for _, c := range s.Connections() {
+		snap.Connections = append(snap.Connections, SnapshotConn{
+			RemoteAddr: c.RemoteAddr.String(),
+			State:      c.State.String(),
+			Opened:     c.Opened,
+			LastRead:   c.LastRead,
+			LastWrite:  c.LastWrite,
+		})
+	}
+	return snap
+}
+
+// SnapshotJSON returns the current state of the server encoded as JSON.
+func (s *GracefulServer) SnapshotJSON() ([]byte, error) {
+	return json.Marshal(s.Snapshot())
+}
@@ -0,0 +1,129 @@
+package manners
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:           new(http.Server),
+		Name:             "api",
+		DrainTimeout:     time.Minute,
+		TrackConnections: true,
+	})
+	started := make(chan bool)
+	server.Handler = blockingHandler(started)
+	client, errc := server.ServeInMemory()
+
+	go client.Get("http://manners/")
+	<-started
+	h := server.Hold("kafka-flush")
+	defer h.Release()
+
+	b, err := server.SnapshotJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Phase != "serving" || snap.Routines != 2 || snap.InFlight != 1 || snap.Result != nil {
+		t.Errorf("Unexpected snapshot %s", b)
+	}
+	if len(snap.Connections) != 1 || snap.Connections[0].State != "active" {
+		t.Errorf("Unexpected connections %+v", snap.Connections)
+	}
+	if len(snap.Holds) != 1 || snap.Holds[0].Name != "kafka-flush" {
+		t.Errorf("Unexpected holds %+v", snap.Holds)
+	}
+	if snap.Config.DrainTimeoutMs != 60000 || !snap.Config.TrackConnections {
+		t.Errorf("Unexpected config %+v", snap.Config)
+	}
+
+	h.Release()
+	server.Stop()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Tests the JSON encoding of a snapshot against a golden copy.
+func TestSnapshotJSONGolden(t *testing.T) {
+	server := NewWithOptions(Options{
+		Server:            &http.Server{Addr: "localhost:8080"},
+		Name:              "api",
+		DrainDelay:        1500 * time.Millisecond,
+		DrainTimeout:      time.Minute,
+		DrainRequestLimit: 10,
+		HijackPolicy:      HijackDrain,
+	})
+	h := server.Hold("kafka-flush")
+	defer h.Release()
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	snap := server.Snapshot()
+	snap.PID = 1234
+	snap.Time = at
+	snap.Holds[0].Since = at
+	snap.Result = newSnapshotResult(&DrainResult{
+		ShutdownStarted:   at,
+		ListenerClosed:    at.Add(time.Second),
+		RequestsDone:      at.Add(2 * time.Second),
+		Finished:          at.Add(2500 * time.Millisecond),
+		InFlight:          2,
+		InFlightDurations: []time.Duration{250 * time.Microsecond, 1200 * time.Millisecond},
+	})
+
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != goldenSnapshot {
+		t.Errorf("Unexpected snapshot JSON\n%s\nexpected\n%s", b, goldenSnapshot)
+	}
+}
+
+const goldenSnapshot = `{
+  "server": "api",
+  "address": "localhost:8080",
+  "pid": 1234,
+  "time": "2020-01-02T03:04:05Z",
+  "phase": "starting",
+  "routines": 1,
+  "in_flight": 0,
+  "drained": 0,
+  "holds": [
+    {
+      "name": "kafka-flush",
+      "since": "2020-01-02T03:04:05Z"
+    }
+  ],
+  "config": {
+    "drain_delay_ms": 1500,
+    "drain_timeout_ms": 60000,
+    "streaming_grace_ms": 0,
+    "drain_silence_limit_ms": 0,
+    "drain_progress_interval_ms": 0,
+    "drain_request_limit": 10,
+    "max_conn_age_ms": 0,
+    "hijack_policy": "drain",
+    "track_connections": false
+  },
+  "result": {
+    "shutdown_started": "2020-01-02T03:04:05Z",
+    "deregistered": "0001-01-01T00:00:00Z",
+    "listener_closed": "2020-01-02T03:04:06Z",
+    "requests_done": "2020-01-02T03:04:07Z",
+    "finished": "2020-01-02T03:04:07.5Z",
+    "duration_ms": 2500,
+    "in_flight": 2,
+    "in_flight_durations_ms": [
+      0.25,
+      1200
+    ]
+  }
+}`
@@ -1,15 +1,21 @@
 package manners
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"sync"
 )
 
 var (
 	defaultServer  *GracefulServer
 	defaultSignals []os.Signal
 	hasSignals     = false
+
+	namedMutex   sync.Mutex
+	namedServers = make(map[string]*NamedServer)
 )
 
 func preventReEntrance() {
@@ -51,6 +57,70 @@ func Serve(l net.Listener, handler http.Handler) error {
 	return defaultServer.Serve(l)
 }
 
+// ServeTLS provides a graceful version of the function provided by the net/http
+// package. Call Close() to stop the server.
+func ServeTLS(l net.Listener, handler http.Handler, certFile, keyFile string) error {
+	preventReEntrance()
+	defaultServer = NewWithServer(&http.Server{Handler: handler})
+	if hasSignals {
+		defaultServer.CloseOnInterrupt(defaultSignals...)
+	}
+	return defaultServer.ServeTLS(l, certFile, keyFile)
+}
+
+// ListenAndServeContext is like ListenAndServe, except that the server also shuts
+// down gracefully when ctx is cancelled. It returns once the server has drained.
+func ListenAndServeContext(ctx context.Context, addr string, handler http.Handler) error {
+	preventReEntrance()
+	server := NewWithServer(&http.Server{Addr: addr, Handler: handler})
+	defaultServer = server
+	if hasSignals {
+		server.CloseOnInterrupt(defaultSignals...)
+	}
+	return serveContext(ctx, server, server.ListenAndServe)
+}
+
+// ListenAndServeTLSContext is like ListenAndServeTLS, except that the server also
+// shuts down gracefully when ctx is cancelled. It returns once the server has drained.
+func ListenAndServeTLSContext(ctx context.Context, addr string, certFile string, keyFile string, handler http.Handler) error {
+	preventReEntrance()
+	server := NewWithServer(&http.Server{Addr: addr, Handler: handler})
+	defaultServer = server
+	if hasSignals {
+		server.CloseOnInterrupt(defaultSignals...)
+	}
+	return serveContext(ctx, server, func() error {
+		return server.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// serveContext runs serve, closing the server if ctx is cancelled first. When
+// serve returns, the default server is released so that it can be started again.
+func serveContext(ctx context.Context, server *GracefulServer, serve func() error) error {
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// If serving has not started, it may never do so, in which case
+			// Close would block.
+			if !server.closeBeforeServe() {
+				server.Close()
+			}
+		case <-finished:
+		}
+	}()
+	err := serve()
+	if defaultServer == server {
+		defaultServer = nil
+	}
+	if err == http.ErrServerClosed && ctx.Err() != nil {
+		// cancelled before serving started
+		err = nil
+	}
+	return err
+}
+
 // Shuts down the default server used by ListenAndServe, ListenAndServeTLS and
 // Serve. It returns true if it's the first time Close is called.
 func Close() bool {
@@ -59,6 +129,97 @@ func Close() bool {
 	return outcome
 }
 
+// CloseAll shuts down the default server, if it is running, and all the named
+// servers that are running.
+func CloseAll() {
+	if defaultServer != nil {
+		Close()
+	}
+	namedMutex.Lock()
+	servers := make([]*NamedServer, 0, len(namedServers))
+	for _, n := range namedServers {
+		servers = append(servers, n)
+	}
+	namedMutex.Unlock()
+	for _, n := range servers {
+		n.Close()
+	}
+}
+
+// A NamedServer is a default server identified by name, allowing programs that
+// serve several ports via the package-level functions to close them selectively
+// or all at once. CloseOnInterrupt applies to named servers too.
+type NamedServer struct {
+	name   string
+	mutex  sync.Mutex
+	server *GracefulServer
+}
+
+// Named returns the default server with the given name, eg.
+//
+//	go manners.Named("admin").ListenAndServe(":8081", adminHandler)
+//	manners.Named("api").ListenAndServe(":8080", apiHandler)
+func Named(name string) *NamedServer {
+	namedMutex.Lock()
+	defer namedMutex.Unlock()
+	n, ok := namedServers[name]
+	if !ok {
+		n = &NamedServer{name: name}
+		namedServers[name] = n
+	}
+	return n
+}
+
+func (n *NamedServer) start(server *http.Server) *GracefulServer {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.server != nil {
+		panic(fmt.Sprintf("Program error: the server named %q must be closed before re-use.", n.name))
+	}
+	n.server = NewWithServer(server)
+	if hasSignals {
+		n.server.CloseOnInterrupt(defaultSignals...)
+	}
+	return n.server
+}
+
+// ListenAndServe provides a graceful version of the function provided by the
+// net/http package. Call Close() to stop the server.
+func (n *NamedServer) ListenAndServe(addr string, handler http.Handler) error {
+	return n.start(&http.Server{Addr: addr, Handler: handler}).ListenAndServe()
+}
+
+// ListenAndServeTLS provides a graceful version of the function provided by the
+// net/http package. Call Close() to stop the server.
+func (n *NamedServer) ListenAndServeTLS(addr string, certFile string, keyFile string, handler http.Handler) error {
+	return n.start(&http.Server{Addr: addr, Handler: handler}).ListenAndServeTLS(certFile, keyFile)
+}
+
+// Serve provides a graceful version of the function provided by the net/http
+// package. Call Close() to stop the server.
+func (n *NamedServer) Serve(l net.Listener, handler http.Handler) error {
+	return n.start(&http.Server{Handler: handler}).Serve(l)
+}
+
+// ServeTLS provides a graceful version of the function provided by the net/http
+// package. Call Close() to stop the server.
+func (n *NamedServer) ServeTLS(l net.Listener, handler http.Handler, certFile, keyFile string) error {
+	return n.start(&http.Server{Handler: handler}).ServeTLS(l, certFile, keyFile)
+}
+
+// Close shuts down the named server. It returns true if the server was running
+// and this is the first time Close is called.
+func (n *NamedServer) Close() bool {
+	n.mutex.Lock()
+	server := n.server
+	n.server = nil
+	n.mutex.Unlock()
+	if server == nil {
+		return false
+	}
+	return server.Close()
+}
+
 // CloseOnInterrupt creates a go-routine that will call the Close() function when certain OS
 // signals are received. If no signals are specified,
 // the following are used: SIGINT, SIGTERM, SIGKILL, SIGQUIT, SIGHUP, SIGUSR1.
@@ -0,0 +1,155 @@
+package manners
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestListenAndServeContext(t *testing.T) {
+	serveUntilCancelled(t)
+}
+
+// Tests that the default server is released when the context is cancelled, so
+// that it can be started again.
+func TestListenAndServeContextTwice(t *testing.T) {
+	serveUntilCancelled(t)
+	serveUntilCancelled(t)
+}
+
+func serveUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- ListenAndServeContext(ctx, "localhost:0", nullHandler)
+	}()
+
+	select {
+	case <-exitchan:
+		t.Fatal("Server exited before the context was cancelled")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down when the context was cancelled")
+	}
+}
+
+// Tests that a cancelled context does not leak a goroutine when the server
+// cannot listen.
+func TestListenAndServeContextUnusableAddr(t *testing.T) {
+	taken, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		if err := ListenAndServeContext(ctx, taken.Addr().String(), nullHandler); err == nil {
+			t.Error("Expected an error for an address in use")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Errorf("Expected no goroutines to leak; %d before, %d after", before, after)
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	defer func() { defaultServer = nil }()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan bool, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case served <- true:
+		default:
+		}
+	})
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- Serve(l, handler)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	<-served
+
+	if !Close() {
+		t.Error("Expected the first Close to return true")
+	}
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+func TestNamedServers(t *testing.T) {
+	if Named("api") != Named("api") {
+		t.Fatal("Named returned different servers for the same name")
+	}
+
+	serve := func(name string) chan error {
+		exitchan := make(chan error)
+		go func() {
+			exitchan <- Named(name).ListenAndServe("localhost:0", nullHandler)
+		}()
+		for {
+			n := Named(name)
+			n.mutex.Lock()
+			running := n.server != nil
+			n.mutex.Unlock()
+			if running {
+				return exitchan
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	api := serve("api")
+	admin := serve("admin")
+
+	if !Named("api").Close() {
+		t.Error("Expected the first Close to return true")
+	}
+	select {
+	case err := <-api:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Named server did not shut down")
+	}
+	select {
+	case <-admin:
+		t.Fatal("Closing one named server closed another")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	CloseAll()
+	select {
+	case err := <-admin:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Named server did not shut down on CloseAll")
+	}
+}
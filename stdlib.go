@@ -0,0 +1,101 @@
+package manners
+
+import (
+	"context"
+	"sync"
+)
+
+// Shutdown provides a graceful equivalent of net/http.Server.Shutdown. It starts
+// shutting down, as per Close, and waits until all in-flight requests have completed
+// or the context is done, in which case it returns the context's error. If the server
+// has not started serving, Shutdown returns nil immediately and any later call to
+// Serve returns http.ErrServerClosed, as with net/http.
+func (s *GracefulServer) Shutdown(ctx context.Context) error {
+	if s.closeBeforeServe() {
+		return nil
+	}
+	go s.Close()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RegisterOnShutdown provides an equivalent of net/http.Server.RegisterOnShutdown.
+// The function f is called in its own goroutine when shutdown starts, whether by
+// Close, Shutdown or a signal. This can be used to tell long-lived or hijacked
+// connections, such as websockets, to close.
+func (s *GracefulServer) RegisterOnShutdown(f func()) {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+// runOnShutdown starts the shutdown functions. They are traced by a span that
+// ends when they have all returned.
+func (s *GracefulServer) runOnShutdown() {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	if len(s.onShutdown) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, f := range s.onShutdown {
+		wg.Add(1)
+		go func(f func()) {
+			defer wg.Done()
+			f()
+		}(f)
+	}
+	if span := s.trace.span("manners.finalizers"); span != nil {
+		go func() {
+			wg.Wait()
+			span.End()
+		}()
+	}
+}
+
+// A StdlibServer adapts a GracefulServer to the lifecycle method set of
+// *net/http.Server, so that it can be used by frameworks that accept anything
+// with Shutdown(ctx) and Close() methods. The only difference from GracefulServer
+// is that Close closes all connections immediately, as per Stop, and returns an
+// error, as does net/http.Server.Close.
+//
+// GracefulServer itself cannot provide this because its Close method has a
+// different signature and meaning, for compatibility with earlier versions.
+type StdlibServer struct {
+	*GracefulServer
+}
+
+// Stdlib returns the server adapted to the lifecycle method set of *net/http.Server.
+func (s *GracefulServer) Stdlib() StdlibServer {
+	return StdlibServer{s}
+}
+
+// Close closes the listener and all connections immediately.
+func (s StdlibServer) Close() error {
+	return s.Stop()
+}
+
+// closeBeforeServe marks the server closed, so that it will not serve, if it has
+// not yet started serving. It reports whether it did so.
+func (s *GracefulServer) closeBeforeServe() bool {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	if s.started {
+		return false
+	}
+	s.closedEarly = true
+	return true
+}
+
+// beginServing records that the server has started serving. It returns false
+// if the server was closed beforehand, in which case it must not serve.
+func (s *GracefulServer) beginServing() bool {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	s.started = true
+	return !s.closedEarly
+}
@@ -0,0 +1,95 @@
+package manners
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type stdlibLifecycle interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+	Close() error
+	RegisterOnShutdown(f func())
+}
+
+// Test that the adapter has the same lifecycle methods as net/http.Server.
+func TestStdlibInterface(t *testing.T) {
+	var original, ours interface{}
+	original = &http.Server{}
+	ours = NewServer().Stdlib()
+	if _, ok := original.(stdlibLifecycle); !ok {
+		t.Errorf("stdlibLifecycle definition does not match the canonical server!")
+	}
+	if _, ok := ours.(stdlibLifecycle); !ok {
+		t.Errorf("StdlibServer does not implement stdlibLifecycle")
+	}
+}
+
+func TestShutdownContext(t *testing.T) {
+	release := make(chan bool)
+	server := NewServer()
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	hookCalled := make(chan bool, 1)
+	server.RegisterOnShutdown(func() { hookCalled <- true })
+
+	statechanged := make(chan http.ConnState, 100)
+	listener, exitchan := startServer(t, server, statechanged)
+
+	client := newClient(listener.Addr(), false)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	waitForState(t, statechanged, http.StateActive, "Client failed to reach active state")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	select {
+	case <-hookCalled:
+	case <-time.After(time.Second):
+		t.Error("Shutdown hook was not called")
+	}
+
+	close(release)
+	<-client.response
+	close(client.sendrequest)
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Error("Unexpected error from Shutdown", err)
+	}
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+}
+
+// Tests that a server shut down before it starts serving refuses to serve, as
+// net/http.Server does.
+func TestShutdownBeforeServe(t *testing.T) {
+	server := NewWithServer(&http.Server{Addr: "localhost:0", Handler: nullHandler})
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Error("Unexpected error from Shutdown", err)
+	}
+
+	exitchan := make(chan error, 1)
+	go func() {
+		exitchan <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-exitchan:
+		if err != http.ErrServerClosed {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		server.Close()
+		t.Fatal("Server started serving after Shutdown")
+	}
+}
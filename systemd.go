@@ -0,0 +1,100 @@
+package manners
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+var (
+	systemdOnce      sync.Once
+	systemdListeners map[string][]net.Listener
+	systemdErr       error
+)
+
+// SystemdListeners returns the listening sockets passed to the process by systemd socket
+// activation, keyed by the FileDescriptorName set in the socket unit. Sockets without a
+// name are keyed "unknown", as per systemd's convention. The sockets are only collected
+// once, so this may be called repeatedly.
+func SystemdListeners() (map[string][]net.Listener, error) {
+	systemdOnce.Do(func() {
+		var names []string
+		names, systemdErr = parseListenFDs(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"),
+			os.Getenv("LISTEN_FDNAMES"), os.Getpid())
+		if systemdErr != nil {
+			return
+		}
+
+		systemdListeners = make(map[string][]net.Listener)
+		for i, name := range names {
+			fd := listenFDsStart + i
+			file := os.NewFile(uintptr(fd), name)
+			l, err := net.FileListener(file)
+			file.Close()
+			if err != nil {
+				systemdErr = fmt.Errorf("systemd socket %d (%s): %v", fd, name, err)
+				return
+			}
+			systemdListeners[name] = append(systemdListeners[name], l)
+		}
+	})
+	return systemdListeners, systemdErr
+}
+
+// SystemdListener returns the listening socket passed by systemd socket activation with
+// the given FileDescriptorName. This allows a unit with, say, separate "http" and "metrics"
+// sockets to route each to the right GracefulServer, eg.
+//
+//	l, err := manners.SystemdListener("http")
+//	...
+//	s := manners.NewWithOptions(manners.Options{Server: server, Listener: l})
+//
+// It is an error if there is not exactly one socket with that name.
+func SystemdListener(name string) (net.Listener, error) {
+	all, err := SystemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	switch len(all[name]) {
+	case 0:
+		return nil, fmt.Errorf("no systemd socket named %q", name)
+	case 1:
+		return all[name][0], nil
+	default:
+		return nil, fmt.Errorf("%d systemd sockets named %q", len(all[name]), name)
+	}
+}
+
+// parseListenFDs interprets the socket activation environment variables, returning the
+// name of each passed file descriptor in order.
+func parseListenFDs(listenPID, listenFDs, listenFDNames string, pid int) ([]string, error) {
+	if listenPID == "" {
+		return nil, nil
+	}
+	if p, err := strconv.Atoi(listenPID); err != nil || p != pid {
+		// the sockets were meant for another process
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(listenFDs)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", listenFDs)
+	}
+
+	names := make([]string, n)
+	given := strings.Split(listenFDNames, ":")
+	for i := range names {
+		if i < len(given) && given[i] != "" {
+			names[i] = given[i]
+		} else {
+			names[i] = "unknown"
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,26 @@
+package manners
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListenFDs(t *testing.T) {
+	names, err := parseListenFDs("123", "3", "http:metrics", 123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"http", "metrics", "unknown"}) {
+		t.Errorf("Unexpected names %v", names)
+	}
+
+	if names, _ = parseListenFDs("456", "2", "http:metrics", 123); names != nil {
+		t.Errorf("Sockets for another process should be ignored, got %v", names)
+	}
+	if names, _ = parseListenFDs("", "", "", 123); names != nil {
+		t.Errorf("Expected no sockets, got %v", names)
+	}
+	if _, err = parseListenFDs("123", "x", "", 123); err == nil {
+		t.Error("Expected an error for invalid LISTEN_FDS")
+	}
+}
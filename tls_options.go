@@ -0,0 +1,59 @@
+package manners
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// DefaultTLSCipherSuites are the TLS 1.2 cipher suites used unless others are
+// configured: only forward-secret AEAD suites are included. TLS 1.3 suites are
+// not configurable and are always enabled.
+var DefaultTLSCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+var errUnsafeKeyLog = errors.New("TLSKeyLogWriter requires UnsafeTLSKeyLog to be set")
+
+// tlsConfig returns a copy of config with the server's TLS options applied.
+// Fields the caller has set are kept unless overridden by an option; a nil
+// config is treated as empty.
+func (s *GracefulServer) tlsConfig(config *tls.Config) (*tls.Config, error) {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+
+	switch {
+	case s.tlsMinVersion != 0:
+		config.MinVersion = s.tlsMinVersion
+	case config.MinVersion == 0:
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	switch {
+	case s.tlsCipherSuites != nil:
+		config.CipherSuites = s.tlsCipherSuites
+	case config.CipherSuites == nil:
+		config.CipherSuites = DefaultTLSCipherSuites
+	}
+
+	if s.tlsCurvePreferences != nil {
+		config.CurvePreferences = s.tlsCurvePreferences
+	}
+
+	if s.tlsKeyLogWriter != nil {
+		if !s.unsafeTLSKeyLog {
+			return nil, errUnsafeKeyLog
+		}
+		logger.Printf("WARNING: TLS session keys for %s are being logged; connections are not private\n", s.ident())
+		config.KeyLogWriter = s.tlsKeyLogWriter
+	}
+
+	return config, nil
+}
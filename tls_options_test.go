@@ -0,0 +1,94 @@
+package manners
+
+import (
+	"bytes"
+	"crypto/tls"
+	helpers "github.com/rickb777/manners/test_helpers"
+	"net/http"
+	"testing"
+)
+
+func TestTLSKeyLogWriter(t *testing.T) {
+	keyFile, err1 := helpers.NewTempFile(helpers.Key)
+	certFile, err2 := helpers.NewTempFile(helpers.Cert)
+	defer keyFile.Unlink()
+	defer certFile.Unlink()
+	if err1 != nil || err2 != nil {
+		t.Fatal("Failed to create temporary files", err1, err2)
+	}
+
+	keyLog := &bytes.Buffer{}
+
+	server := NewWithOptions(Options{Server: new(http.Server), TLSKeyLogWriter: keyLog})
+	if err := server.ListenAndServeTLS(certFile.Name(), keyFile.Name()); err != errUnsafeKeyLog {
+		t.Fatalf("Expected %v, got %v", errUnsafeKeyLog, err)
+	}
+
+	server = NewWithOptions(Options{Server: new(http.Server), TLSKeyLogWriter: keyLog, UnsafeTLSKeyLog: true})
+	listener, exitchan := startTLSServer(t, server, certFile.Name(), keyFile.Name(), nil)
+
+	client := newClient(listener.Addr(), true)
+	client.Run()
+	if err := <-client.connected; err != nil {
+		t.Fatal("Client failed to connect to server", err)
+	}
+	client.sendrequest <- true
+	if rr := <-client.response; rr.err != nil {
+		t.Fatal("Unexpected error from client", rr.err)
+	}
+	close(client.sendrequest)
+	<-client.closed
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	if keyLog.Len() == 0 {
+		t.Error("No TLS session keys were logged")
+	}
+}
+
+func TestTLSConfigDefaults(t *testing.T) {
+	server := NewServer()
+	config, err := server.tlsConfig(&tls.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 minimum, got %x", config.MinVersion)
+	}
+	if len(config.CipherSuites) != len(DefaultTLSCipherSuites) {
+		t.Errorf("Expected default cipher suites, got %v", config.CipherSuites)
+	}
+
+	config, err = server.tlsConfig(nil)
+	if err != nil || config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected defaults for a nil config, got %v %v", config, err)
+	}
+
+	suites := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	config, err = server.tlsConfig(&tls.Config{MinVersion: tls.VersionTLS13, CipherSuites: suites})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 || len(config.CipherSuites) != 1 {
+		t.Errorf("Expected the caller's settings to be kept, got %x %v", config.MinVersion, config.CipherSuites)
+	}
+
+	server = NewWithOptions(Options{
+		Server:              new(http.Server),
+		TLSMinVersion:       tls.VersionTLS13,
+		TLSCurvePreferences: []tls.CurveID{tls.X25519},
+	})
+	config, err = server.tlsConfig(&tls.Config{MinVersion: tls.VersionTLS11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %x", config.MinVersion)
+	}
+	if len(config.CurvePreferences) != 1 || config.CurvePreferences[0] != tls.X25519 {
+		t.Errorf("Unexpected curve preferences %v", config.CurvePreferences)
+	}
+}
@@ -0,0 +1,85 @@
+package manners
+
+import (
+	"context"
+	"sync"
+)
+
+// A Tracer starts tracing spans. Implement it with a small adapter for your tracing
+// library, eg. OpenTelemetry, so that distributed traces show where the shutdown of
+// a server spends its time.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a traced operation started by a Tracer.
+type Span interface {
+	End()
+}
+
+// shutdownTrace records the shutdown sequence as a root span with a child span for
+// each step. It does nothing if there is no tracer. The steps happen in different
+// goroutines, hence the mutex.
+type shutdownTrace struct {
+	tracer Tracer
+	mutex  sync.Mutex
+	ctx    context.Context
+	root   Span
+	child  Span
+}
+
+func (t *shutdownTrace) begin() {
+	if t.tracer == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ctx, t.root = t.tracer.Start(context.Background(), "manners.shutdown")
+}
+
+// step ends the current step, if any, and starts the next.
+func (t *shutdownTrace) step(name string) {
+	if t.tracer == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.root == nil {
+		return
+	}
+	if t.child != nil {
+		t.child.End()
+	}
+	_, t.child = t.tracer.Start(t.ctx, name)
+}
+
+// span starts a child span that runs alongside the steps, or returns nil if
+// there is no tracer or the shutdown is not being traced.
+func (t *shutdownTrace) span(name string) Span {
+	if t.tracer == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.root == nil {
+		return nil
+	}
+	_, span := t.tracer.Start(t.ctx, name)
+	return span
+}
+
+func (t *shutdownTrace) end() {
+	if t.tracer == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.child != nil {
+		t.child.End()
+		t.child = nil
+	}
+	if t.root != nil {
+		t.root.End()
+		t.root = nil
+	}
+}
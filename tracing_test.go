@@ -0,0 +1,77 @@
+package manners
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	mutex sync.Mutex
+	ended []string
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	name   string
+}
+
+func (rt *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, &recordingSpan{rt, name}
+}
+
+func (rs *recordingSpan) End() {
+	rs.tracer.mutex.Lock()
+	defer rs.tracer.mutex.Unlock()
+	rs.tracer.ended = append(rs.tracer.ended, rs.name)
+}
+
+func TestShutdownTracing(t *testing.T) {
+	tracer := &recordingTracer{}
+	server := NewWithOptions(Options{Server: new(http.Server), Tracer: tracer, DrainDelay: time.Millisecond})
+	_, exitchan := startServer(t, server, nil)
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	expected := []string{"manners.drain_delay", "manners.listener_close", "manners.request_wait", "manners.shutdown"}
+	if !reflect.DeepEqual(tracer.ended, expected) {
+		t.Errorf("Expected spans %v, got %v", expected, tracer.ended)
+	}
+}
+
+// Tests that the shutdown functions are traced by a span that ends when they
+// have all returned.
+func TestShutdownTracingFinalizers(t *testing.T) {
+	tracer := &recordingTracer{}
+	server := NewWithOptions(Options{Server: new(http.Server), Tracer: tracer})
+	release := make(chan struct{})
+	server.RegisterOnShutdown(func() { <-release })
+	_, exitchan := startServer(t, server, nil)
+
+	server.Close()
+	if err := <-exitchan; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+	close(release)
+
+	expected := []string{"manners.listener_close", "manners.request_wait", "manners.shutdown", "manners.finalizers"}
+	deadline := time.Now().Add(time.Second)
+	for {
+		tracer.mutex.Lock()
+		ended := append([]string(nil), tracer.ended...)
+		tracer.mutex.Unlock()
+		if reflect.DeepEqual(ended, expected) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected spans %v, got %v", expected, ended)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
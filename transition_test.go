@@ -1,7 +1,9 @@
 package manners
 
 import (
+	"fmt"
 	helpers "github.com/rickb777/manners/test_helpers"
+	"net"
 	"net/http"
 	"strings"
 	"testing"
@@ -36,7 +38,7 @@ func testStateTransition(t *testing.T, test transitionTest) {
 	server.wg = wg
 	startServer(t, server, nil)
 
-	conn := &gracefulConn{Conn: &helpers.Conn{}}
+	conn := newGracefulConn(&helpers.Conn{})
 	for _, newState := range test.states {
 		server.ConnState(conn, newState)
 	}
@@ -52,3 +54,132 @@ func testStateTransition(t *testing.T, test transitionTest) {
 		t.Errorf("%s - Waitcount should be %d, got %d", transitions, test.expectedWgCount, waiting)
 	}
 }
+
+var connStateCycle = []http.ConnState{http.StateNew, http.StateActive, http.StateIdle, http.StateActive, http.StateClosed}
+
+// Test that tracking connection state changes does not allocate, because
+// it happens on every request.
+func TestConnStateAllocs(t *testing.T) {
+	server := NewServer()
+	_, exitchan := startServer(t, server, nil)
+
+	conn := newGracefulConn(&helpers.Conn{})
+	allocs := testing.AllocsPerRun(1000, func() {
+		for _, newState := range connStateCycle {
+			server.ConnState(conn, newState)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+
+	server.Close()
+	<-exitchan
+}
+
+func BenchmarkConnState(b *testing.B) {
+	server := NewServer()
+	server.Addr = "localhost:0"
+	server.Handler = nullHandler
+	server.up = make(chan net.Listener)
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- server.ListenAndServe()
+	}()
+	<-server.up
+
+	conn := newGracefulConn(&helpers.Conn{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, newState := range connStateCycle {
+			server.ConnState(conn, newState)
+		}
+	}
+	b.StopTimer()
+
+	server.Close()
+	<-exitchan
+}
+
+// Test that connection records are reused when pooling is enabled and that
+// the reused records start afresh.
+func TestPoolConns(t *testing.T) {
+	server := NewWithOptions(Options{Server: new(http.Server), PoolConns: true})
+	wg := helpers.NewWaitGroup()
+	server.wg = wg
+	_, exitchan := startServer(t, server, nil)
+
+	for i := 0; i < 100; i++ {
+		conn := newGracefulConn(&helpers.Conn{})
+		if conn.lastHTTPState != http.StateNew || conn.protected {
+			t.Fatalf("Reused connection record was not reset: %+v", conn)
+		}
+		for _, newState := range connStateCycle {
+			server.ConnState(conn, newState)
+		}
+	}
+
+	server.Close()
+	if waiting := <-wg.WaitCalled; waiting != 0 {
+		t.Errorf("Waitcount should be zero, got %d", waiting)
+	}
+	<-exitchan
+}
+
+func BenchmarkConnLifecycle(b *testing.B) {
+	for _, pool := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pool=%t", pool), func(b *testing.B) {
+			server := NewWithOptions(Options{Server: new(http.Server), PoolConns: pool})
+			server.Addr = "localhost:0"
+			server.Handler = nullHandler
+			server.up = make(chan net.Listener)
+			exitchan := make(chan error)
+			go func() {
+				exitchan <- server.ListenAndServe()
+			}()
+			<-server.up
+
+			inner := &helpers.Conn{}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				conn := newGracefulConn(inner)
+				for _, newState := range connStateCycle {
+					server.ConnState(conn, newState)
+				}
+			}
+			b.StopTimer()
+
+			server.Close()
+			<-exitchan
+		})
+	}
+}
+
+func BenchmarkConnStateParallel(b *testing.B) {
+	server := NewServer()
+	server.Addr = "localhost:0"
+	server.Handler = nullHandler
+	server.up = make(chan net.Listener)
+	exitchan := make(chan error)
+	go func() {
+		exitchan <- server.ListenAndServe()
+	}()
+	<-server.up
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		conn := newGracefulConn(&helpers.Conn{})
+		for pb.Next() {
+			for _, newState := range connStateCycle {
+				server.ConnState(conn, newState)
+			}
+		}
+	})
+	b.StopTimer()
+
+	server.Close()
+	<-exitchan
+}
@@ -0,0 +1,141 @@
+package manners
+
+import (
+	"math/rand"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// CloseOnFile creates a go-routine that polls the file at path and calls the Close() function
+// when the file appears or changes. This supports deployment systems that communicate via
+// drop-files rather than signals. If interval is zero, the file is checked every second.
+// This function must be called before ListenAndServe, ListenAndServeTLS, or Serve.
+func (s *GracefulServer) CloseOnFile(path string, interval time.Duration) *GracefulServer {
+	if s == nil {
+		panic("Program error: the server must exist before this method is called.")
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	initial, initialErr := os.Stat(path)
+	go func(rx *GracefulServer) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rx.done:
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				if initialErr != nil || !info.ModTime().Equal(initial.ModTime()) || info.Size() != initial.Size() {
+					logger.Printf("Sentinel file %s detected for %s\n", path, rx.ident())
+					rx.Close()
+					return
+				}
+			}
+		}
+	}(s)
+	return s
+}
+
+// CloseWhenIdle creates a go-routine that calls the Close() function once no requests have been
+// served for the period d, supporting scale-to-zero deployments and on-demand workers.
+// Idleness is checked four times per period, but at most once per millisecond.
+// This function must be called before ListenAndServe, ListenAndServeTLS, or Serve.
+func (s *GracefulServer) CloseWhenIdle(d time.Duration) *GracefulServer {
+	if s == nil {
+		panic("Program error: the server must exist before this method is called.")
+	}
+	interval := d / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	go func(rx *GracefulServer) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rx.done:
+				return
+			case now := <-ticker.C:
+				if atomic.LoadInt32(&rx.phase) != phaseServing || atomic.LoadInt32(&rx.active) > 0 {
+					continue
+				}
+				last := time.Unix(0, atomic.LoadInt64(&rx.lastActivity))
+				if now.Sub(last) >= d {
+					logger.Printf("Server on %s idle for %v\n", rx.ident(), now.Sub(last))
+					rx.Close()
+					return
+				}
+			}
+		}
+	}(s)
+	return s
+}
+
+// CloseAfter creates a go-routine that calls the Close() function once the server has been running
+// for the given lifetime plus a random amount of up to jitter. This supports fleets that recycle
+// their instances regularly; the jitter avoids every instance draining at the same moment.
+// The lifetime is measured from when this method is called.
+// This function must be called before ListenAndServe, ListenAndServeTLS, or Serve.
+func (s *GracefulServer) CloseAfter(lifetime, jitter time.Duration) *GracefulServer {
+	if s == nil {
+		panic("Program error: the server must exist before this method is called.")
+	}
+	if jitter > 0 {
+		lifetime += time.Duration(rand.Int63n(int64(jitter)))
+	}
+	go func(rx *GracefulServer) {
+		timer := time.NewTimer(lifetime)
+		defer timer.Stop()
+		select {
+		case <-rx.done:
+		case <-timer.C:
+			logger.Printf("Server on %s reached its maximum lifetime of %v\n", rx.ident(), lifetime)
+			rx.Close()
+		}
+	}(s)
+	return s
+}
+
+// CloseOnMemory creates a go-routine that checks the memory used by the process every interval
+// and, when it exceeds limit bytes, calls hook or, if hook is nil, the Close() function. This lets
+// leaking services degrade gracefully instead of being killed mid-request. Memory is measured as
+// the resident set size on Linux and as the allocated heap elsewhere. If interval is zero, memory
+// is checked every ten seconds. The check stops after the limit has first been exceeded.
+// This function must be called before ListenAndServe, ListenAndServeTLS, or Serve.
+func (s *GracefulServer) CloseOnMemory(limit uint64, interval time.Duration, hook func(usage uint64)) *GracefulServer {
+	if s == nil {
+		panic("Program error: the server must exist before this method is called.")
+	}
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go func(rx *GracefulServer) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rx.done:
+				return
+			case <-ticker.C:
+				usage, err := memoryUsage()
+				if err != nil || usage <= limit {
+					continue
+				}
+				logger.Printf("Memory usage %d exceeds limit %d for %s\n", usage, limit, rx.ident())
+				if hook != nil {
+					hook(usage)
+				} else {
+					rx.Close()
+				}
+				return
+			}
+		}
+	}(s)
+	return s
+}
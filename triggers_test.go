@@ -0,0 +1,91 @@
+package manners
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCloseOnFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drain")
+
+	server := NewServer().CloseOnFile(path, 10*time.Millisecond)
+	_, exitchan := startServer(t, server, nil)
+
+	if err := ioutil.WriteFile(path, []byte("now"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down after the sentinel file appeared")
+	}
+}
+
+func TestCloseWhenIdle(t *testing.T) {
+	server := NewServer().CloseWhenIdle(20 * time.Millisecond)
+	_, exitchan := startServer(t, server, nil)
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down when idle")
+	}
+}
+
+// Tests that a period too short to divide into check intervals is allowed.
+func TestCloseWhenIdleShortPeriod(t *testing.T) {
+	server := NewServer().CloseWhenIdle(time.Nanosecond)
+	_, exitchan := startServer(t, server, nil)
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down when idle")
+	}
+}
+
+func TestCloseAfter(t *testing.T) {
+	server := NewServer().CloseAfter(20*time.Millisecond, 10*time.Millisecond)
+	_, exitchan := startServer(t, server, nil)
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down after its lifetime")
+	}
+}
+
+func TestCloseOnMemory(t *testing.T) {
+	server := NewServer().CloseOnMemory(1, 10*time.Millisecond, nil)
+	_, exitchan := startServer(t, server, nil)
+
+	select {
+	case err := <-exitchan:
+		if err != nil {
+			t.Error("Unexpected error during shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not shut down when over its memory limit")
+	}
+}
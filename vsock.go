@@ -0,0 +1,55 @@
+package manners
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vsockAny is VMADDR_CID_ANY, which binds to any context ID.
+const vsockAny = 0xFFFFFFFF
+
+// A VsockAddr is the address of an AF_VSOCK socket, used for communication
+// between virtual machines or enclaves and their host.
+type VsockAddr struct {
+	CID  uint32
+	Port uint32
+}
+
+func (a *VsockAddr) Network() string { return "vsock" }
+
+func (a *VsockAddr) String() string {
+	if a.CID == vsockAny {
+		return fmt.Sprintf("vsock::%d", a.Port)
+	}
+	return fmt.Sprintf("vsock:%d:%d", a.CID, a.Port)
+}
+
+func isVsockNetwork(addr string) bool {
+	return strings.HasPrefix(addr, "vsock:")
+}
+
+// parseVsockAddr parses addresses of the form "vsock:CID:PORT". The CID may be
+// omitted, as in "vsock::8080", to listen on any context ID.
+func parseVsockAddr(addr string) (*VsockAddr, error) {
+	parts := strings.Split(strings.TrimPrefix(addr, "vsock:"), ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("error while parsing vsock address %v", addr)
+	}
+
+	va := &VsockAddr{CID: vsockAny}
+	if parts[0] != "" {
+		cid, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing vsock address %v: %v", addr, err)
+		}
+		va.CID = uint32(cid)
+	}
+
+	port, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing vsock address %v: %v", addr, err)
+	}
+	va.Port = uint32(port)
+	return va, nil
+}
@@ -0,0 +1,107 @@
+//go:build linux && !386
+// +build linux,!386
+
+// On linux/386, socket calls go through socketcall and the raw syscalls used
+// here do not exist, so vsock is unsupported there.
+
+package manners
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const afVsock = 40 // AF_VSOCK
+
+// sockaddrVM mirrors struct sockaddr_vm from <linux/vm_sockets.h>.
+type sockaddrVM struct {
+	Family    uint16
+	Reserved1 uint16
+	Port      uint32
+	CID       uint32
+	Flags     uint8
+	Zero      [3]uint8
+}
+
+// vsockListener is a net.Listener for AF_VSOCK sockets, which the net package
+// does not support. The socket is non-blocking and driven by the runtime poller
+// via an os.File.
+type vsockListener struct {
+	file *os.File
+	raw  syscall.RawConn
+	addr *VsockAddr
+}
+
+func listenVsock(addr *VsockAddr) (net.Listener, error) {
+	fd, err := syscall.Socket(afVsock, syscall.SOCK_STREAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	sa := sockaddrVM{Family: afVsock, Port: addr.Port, CID: addr.CID}
+	_, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa))
+	if errno != 0 {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", errno)
+	}
+
+	if err = syscall.Listen(fd, syscall.SOMAXCONN); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("listen", err)
+	}
+
+	file := os.NewFile(uintptr(fd), addr.String())
+	raw, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &vsockListener{file: file, raw: raw, addr: addr}, nil
+}
+
+func (l *vsockListener) Accept() (net.Conn, error) {
+	var nfd int
+	var sa sockaddrVM
+	var opErr error
+	err := l.raw.Read(func(fd uintptr) bool {
+		size := uint32(unsafe.Sizeof(sa))
+		r, _, errno := syscall.Syscall6(syscall.SYS_ACCEPT4, fd, uintptr(unsafe.Pointer(&sa)), uintptr(unsafe.Pointer(&size)),
+			syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0, 0)
+		if errno == syscall.EAGAIN {
+			return false
+		}
+		nfd, opErr = int(r), nil
+		if errno != 0 {
+			opErr = os.NewSyscallError("accept4", errno)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opErr != nil {
+		return nil, opErr
+	}
+
+	remote := &VsockAddr{CID: sa.CID, Port: sa.Port}
+	return &vsockConn{File: os.NewFile(uintptr(nfd), remote.String()), local: l.addr, remote: remote}, nil
+}
+
+func (l *vsockListener) Close() error {
+	return l.file.Close()
+}
+
+func (l *vsockListener) Addr() net.Addr {
+	return l.addr
+}
+
+// vsockConn is a net.Conn for an accepted AF_VSOCK connection.
+type vsockConn struct {
+	*os.File
+	local, remote *VsockAddr
+}
+
+func (c *vsockConn) LocalAddr() net.Addr  { return c.local }
+func (c *vsockConn) RemoteAddr() net.Addr { return c.remote }
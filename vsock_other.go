@@ -0,0 +1,13 @@
+//go:build !linux || 386
+// +build !linux 386
+
+package manners
+
+import (
+	"errors"
+	"net"
+)
+
+func listenVsock(addr *VsockAddr) (net.Listener, error) {
+	return nil, errors.New("vsock is not supported on this platform")
+}
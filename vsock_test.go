@@ -0,0 +1,32 @@
+package manners
+
+import "testing"
+
+func TestParseVsockAddr(t *testing.T) {
+	cases := []struct {
+		addr      string
+		cid, port uint32
+	}{
+		{"vsock:3:8080", 3, 8080},
+		{"vsock::80", vsockAny, 80},
+	}
+	for _, c := range cases {
+		va, err := parseVsockAddr(c.addr)
+		if err != nil {
+			t.Errorf("%s: %v", c.addr, err)
+			continue
+		}
+		if va.CID != c.cid || va.Port != c.port {
+			t.Errorf("%s: got %+v", c.addr, va)
+		}
+		if va.String() != c.addr {
+			t.Errorf("%s: round trip gave %s", c.addr, va)
+		}
+	}
+
+	for _, bad := range []string{"vsock:", "vsock:x:80", "vsock:3:y", "vsock:1:2:3"} {
+		if _, err := parseVsockAddr(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
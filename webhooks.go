@@ -0,0 +1,98 @@
+package manners
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Webhook events.
+const (
+	WebhookDrainStarted  = "drain_started"
+	WebhookDrainComplete = "drain_complete"
+)
+
+// webhookClient posts the webhook notifications. Its timeout bounds how long
+// the end of Serve can be delayed by a slow receiver.
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// WebhookPayload is the JSON body posted to each of the DrainWebhooks.
+type WebhookPayload struct {
+	Event  string       `json:"event"`
+	Server string       `json:"server"`
+	Host   string       `json:"host"`
+	PID    int          `json:"pid"`
+	Time   time.Time    `json:"time"`
+	Result *DrainResult `json:"result,omitempty"`
+}
+
+// notifyDrainStarted posts the drain_started event without waiting for it to be
+// delivered. The drain_complete event is not posted until it has been.
+func (s *GracefulServer) notifyDrainStarted() {
+	if len(s.webhooks) == 0 {
+		return
+	}
+	s.webhookMutex.Lock()
+	go func() {
+		defer s.webhookMutex.Unlock()
+		s.notifyWebhooks(WebhookDrainStarted, nil)
+	}()
+}
+
+// notifyDrainComplete posts the drain_complete event, once any drain_started
+// event has been delivered, and waits for it to be delivered.
+func (s *GracefulServer) notifyDrainComplete(result *DrainResult) {
+	s.webhookMutex.Lock()
+	defer s.webhookMutex.Unlock()
+	s.notifyWebhooks(WebhookDrainComplete, result)
+}
+
+// notifyWebhooks posts the event to each of the webhook URLs concurrently and
+// waits for them to respond. Failures are logged and otherwise ignored.
+func (s *GracefulServer) notifyWebhooks(event string, result *DrainResult) {
+	if len(s.webhooks) == 0 {
+		return
+	}
+
+	payload := WebhookPayload{
+		Event:  event,
+		Server: s.ident(),
+		PID:    os.Getpid(),
+		Time:   time.Now(),
+		Result: result,
+	}
+	payload.Host, _ = os.Hostname()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Printf("Failed to encode %s webhook for %s: %v\n", event, payload.Server, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, url := range s.webhooks {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if err := postWebhook(url, body); err != nil {
+				logger.Printf("Failed to send %s webhook for %s to %s: %v\n", event, payload.Server, url, err)
+			}
+		}(url)
+	}
+	wg.Wait()
+}
+
+func postWebhook(url string, body []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
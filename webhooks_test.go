@@ -0,0 +1,72 @@
+package manners
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainWebhooks(t *testing.T) {
+	payloads := make(chan WebhookPayload, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error("Invalid webhook payload", err)
+		}
+		payloads <- p
+	}))
+	defer receiver.Close()
+
+	server := NewWithOptions(Options{Server: new(http.Server), DrainWebhooks: []string{receiver.URL}})
+	server.Handler = nullHandler
+	_, errc := server.ServeInMemory()
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	events := make(map[string]WebhookPayload)
+	for i := 0; i < 2; i++ {
+		p := <-payloads
+		events[p.Event] = p
+	}
+	started, ok := events[WebhookDrainStarted]
+	if !ok || started.Server != "pipe" || started.PID == 0 || started.Result != nil {
+		t.Errorf("Unexpected drain started payload %+v", started)
+	}
+	complete, ok := events[WebhookDrainComplete]
+	if !ok || complete.Result == nil || complete.Result.ShutdownStarted.IsZero() {
+		t.Errorf("Unexpected drain complete payload %+v", complete)
+	}
+}
+
+// Tests that drain_complete is not posted before drain_started, even when the
+// receiver is slow and the drain is fast.
+func TestDrainWebhooksOrder(t *testing.T) {
+	events := make(chan string, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error("Invalid webhook payload", err)
+		}
+		if p.Event == WebhookDrainStarted {
+			time.Sleep(50 * time.Millisecond)
+		}
+		events <- p.Event
+	}))
+	defer receiver.Close()
+
+	server := NewWithOptions(Options{Server: new(http.Server), DrainWebhooks: []string{receiver.URL}})
+	server.Handler = nullHandler
+	_, errc := server.ServeInMemory()
+	server.Close()
+	if err := <-errc; err != nil {
+		t.Error("Unexpected error during shutdown", err)
+	}
+
+	if first, second := <-events, <-events; first != WebhookDrainStarted || second != WebhookDrainComplete {
+		t.Errorf("Expected drain_started then drain_complete, got %s then %s", first, second)
+	}
+}